@@ -0,0 +1,111 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// blocklistCmd represents the blocklist command
+var blocklistCmd = &cobra.Command{
+	Use:   "blocklist",
+	Short: "Manage the owners, repositories and refs that are skipped when crawling and exporting",
+}
+
+// blocklistAddCmd represents the blocklist add command
+var blocklistAddCmd = &cobra.Command{
+	Use:   "add <owner|repo|ref> <pattern>",
+	Short: "Add a rule to the blocklist",
+	Args:  cobra.ExactArgs(2),
+	Run:   runBlocklistAdd,
+}
+
+// blocklistRemoveCmd represents the blocklist remove command
+var blocklistRemoveCmd = &cobra.Command{
+	Use:   "remove <owner|repo|ref> <pattern>",
+	Short: "Remove a rule from the blocklist",
+	Args:  cobra.ExactArgs(2),
+	Run:   runBlocklistRemove,
+}
+
+// blocklistListCmd represents the blocklist list command
+var blocklistListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the rules on the blocklist",
+	Args:  cobra.NoArgs,
+	Run:   runBlocklistList,
+}
+
+// Flags
+var (
+	allow bool
+)
+
+// blocklistQuery lists the rules on the blocklist
+const blocklistQuery = "select kind, pattern, action, addedon from blocklist order by kind, pattern"
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(blocklistCmd)
+	blocklistCmd.AddCommand(blocklistAddCmd)
+	blocklistCmd.AddCommand(blocklistRemoveCmd)
+	blocklistCmd.AddCommand(blocklistListCmd)
+	blocklistAddCmd.Flags().BoolVar(&allow, "allow", false, "Allow matching contributions even when a deny rule matches them")
+}
+
+// runBlocklistAdd is the actual execution of the add command
+func runBlocklistAdd(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	l := mustLock()
+	defer l.Release()
+
+	rule := database.Rule{Kind: args[0], Pattern: args[1], Action: database.Deny}
+	if allow {
+		rule.Action = database.Allow
+	}
+
+	if err := db.AddRule(rule, time.Now()); err != nil {
+		log.Fatalf("Error while adding %s %s to the blocklist: %s\n", args[0], args[1], err.Error())
+	}
+	log.Printf("Added rule to %s %s %s\n", rule.Action, rule.Kind, rule.Pattern)
+}
+
+// runBlocklistRemove is the actual execution of the remove command
+func runBlocklistRemove(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	l := mustLock()
+	defer l.Release()
+
+	removed, err := db.RemoveRule(args[0], args[1])
+	if err != nil {
+		log.Fatalf("Error while removing %s %s from the blocklist: %s\n", args[0], args[1], err.Error())
+	}
+	if !removed {
+		log.Fatalf("There is no rule for %s %s on the blocklist\n", args[0], args[1])
+	}
+	log.Printf("Removed the rule for %s %s\n", args[0], args[1])
+}
+
+// runBlocklistList is the actual execution of the list command
+func runBlocklistList(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	queryOpts := database.QueryOptions{
+		Writer:     os.Stdout,
+		Query:      blocklistQuery,
+		MergeCells: true,
+		RowLine:    true,
+		Render:     true,
+	}
+	_, err := db.Query(queryOpts)
+	if err != nil {
+		log.Fatalf("Error while listing the blocklist: %s\n", err.Error())
+	}
+}
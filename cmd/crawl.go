@@ -3,6 +3,7 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -20,53 +21,128 @@ var crawlCmd = &cobra.Command{
 	Run:   runCrawl,
 }
 
+// Flags
+var (
+	githubAPIURL string
+	githubRawURL string
+	concurrency  int
+	permalinks   bool
+	incremental  bool
+	includeForks bool
+)
+
 // init registers the command and flags
 func init() {
 	rootCmd.AddCommand(crawlCmd)
-	crawlCmd.Flags().StringVar(&activityType, "type", "", "The type to look for: trigger, activity, or contribution (required)")
-	crawlCmd.Flags().Float64Var(&timeout, "timeout", 0, "The number of hours between now and the last repo update")
+	crawlCmd.Flags().StringVar(&activityType, "type", "", "The type to look for: trigger, activity, function, action, connection, contribution, or all (required)")
+	crawlCmd.Flags().Float64Var(&timeout, "timeout", 0, "The number of hours between now and the last repo update after which the crawl stops, 0 crawls all results")
+	crawlCmd.Flags().StringVar(&githubAPIURL, "api-url", github.DefaultBaseURL, "The URL of the GitHub API")
+	crawlCmd.Flags().StringVar(&githubRawURL, "raw-url", github.DefaultRawURL, "The URL to download raw file content from GitHub")
+	crawlCmd.Flags().BoolVar(&permalinks, "permalink", false, "Store a link to the commit each descriptor was found in next to the link to the default branch")
+	crawlCmd.Flags().BoolVar(&incremental, "incremental", false, "Only crawl repositories that were pushed to since the last successful crawl for the type")
+	crawlCmd.Flags().BoolVar(&includeForks, "include-forks", false, "Store descriptors found in forks even when they are the same as in the upstream repository")
+	crawlCmd.Flags().IntVar(&concurrency, "concurrency", github.DefaultConcurrency, "The number of files and repositories to fetch from GitHub at the same time")
 	crawlCmd.MarkFlagRequired("type")
 }
 
+// allTypes is the value of --type that crawls all types of contributions in one run
+const allTypes = "all"
+
 // runCrawl is the actual execution of the command
 func runCrawl(cmd *cobra.Command, args []string) {
-	// Creating a file with the current time
-	os.Remove(crawlLockFile)
-	file, err := os.OpenFile(crawlLockFile, os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		log.Printf("Error while opening .crawl file: %s\n", err.Error())
-	}
-	defer file.Close()
-	currentTime := time.Now().String()
-	if _, err = file.WriteString(currentTime); err != nil {
-		log.Printf("Error while writing date to .crawl file: %s\n", err.Error())
-	}
-
 	// This app needs to connect to GitHub using a Personal Access Token
 	githubToken, set := os.LookupEnv("GITHUB_ACCESS_TOKEN")
 	if !set {
 		log.Fatalf("GitHub Access Token is not set. Please set GITHUB_ACCESS_TOKEN before running this command\n")
 	}
 
-	var contributionType github.ContributionIdentifier
-
-	switch strings.ToUpper(activityType) {
-	case github.TriggerType.String():
-		contributionType = github.TriggerType
-	case github.ActivityType.String():
-		contributionType = github.ActivityType
-	case github.ContributionType.String():
-		contributionType = github.ContributionType
-	default:
-		log.Fatalf("Unknown type: %s. Please use either trigger or activity\n", activityType)
+	var contributionTypes []github.ContributionIdentifier
+	if strings.EqualFold(activityType, allTypes) {
+		contributionTypes = github.CrawlTypes(github.ContributionIdentifiers)
+	} else {
+		contributionType, err := github.ParseContributionIdentifier(activityType)
+		if err != nil {
+			log.Fatalf("Unknown type: %s. Please use either trigger, activity, function, action, connection, contribution or all\n", activityType)
+		}
+		contributionTypes = []github.ContributionIdentifier{contributionType}
 	}
 
 	// Get a database
-	db := database.MustOpenSession(databaseFile)
+	db := mustOpenCurrent()
+	db.RunID = database.NewRunID("crawl", time.Now())
+
+	l := mustLock()
+	defer l.Release()
+
+	// All types share the client, so they share the rate limits and the cached repository details, and skip the files
+	// that were already visited for another type
+	client := github.NewClient(github.ClientOptions{
+		Token:   githubToken,
+		BaseURL: githubAPIURL,
+		RawURL:  githubRawURL,
+	})
+	visited := github.NewVisited()
+
+	var total github.CrawlResult
+	var failed []string
+	for _, contributionType := range contributionTypes {
+		result, err := crawlType(db, client, contributionType, visited)
+		if err != nil {
+			log.Printf("Error while crawling for %s: %s\n", contributionType, err.Error())
+			failed = append(failed, contributionType.String())
+			continue
+		}
+		total.Add(result)
+	}
+
+	if len(contributionTypes) > 1 {
+		log.Printf("Completed crawling for %d types! Visited %d of %d pages in %d searches: %d inserted, %d updated, %d unchanged, %d skipped, %d duplicates, %d forks, %d blocked, %d failed\n", len(contributionTypes)-len(failed), total.PagesVisited, total.Pages, total.Shards, total.Inserted, total.Updated, total.Unchanged, total.Skipped, total.Duplicates, total.Forks, total.Blocked, total.Failed)
+	}
+	if len(failed) > 0 {
+		log.Fatalf("Crawling failed for %s\n", strings.Join(failed, ", "))
+	}
+}
 
-	err = github.Crawl(githubToken, db, timeout, contributionType)
+// crawlType crawls GitHub for a single type of contribution and records the crawl in the database when it succeeds
+// without failures.
+func crawlType(db *database.Database, client *github.Client, contributionType github.ContributionIdentifier, visited *github.Visited) (github.CrawlResult, error) {
+	startTime := time.Now()
+
+	var since time.Time
+	if incremental {
+		var err error
+		since, err = db.LastCrawl(contributionType.String())
+		if err != nil {
+			return github.CrawlResult{}, fmt.Errorf("error while reading the last crawl: %s", err.Error())
+		}
+		if since.IsZero() {
+			log.Printf("There is no previous crawl for %s, crawling all repositories\n", contributionType)
+		} else {
+			log.Printf("Crawling repositories for %s that were pushed to since %s\n", contributionType, since.Format(time.RFC3339))
+		}
+	}
+
+	result, err := client.Crawl(db, contributionType, github.CrawlOptions{
+		Timeout:      timeout,
+		Concurrency:  concurrency,
+		Permalinks:   permalinks,
+		Since:        since,
+		Visited:      visited,
+		IncludeForks: includeForks,
+	})
 	if err != nil {
-		log.Fatalf("Error while crawling for %s: %s\n", activityType, err.Error())
+		return result, err
+	}
+	// A result that failed is only tried again when the next incremental crawl doesn't skip its repository, so the
+	// time of the last crawl is only moved forward when nothing failed
+	if result.Failed > 0 {
+		log.Printf("Not recording the crawl for %s, %d files failed and are retried by the next crawl\n", contributionType, result.Failed)
+	} else if err = db.SetLastCrawl(contributionType.String(), startTime); err != nil {
+		log.Printf("Error while recording the crawl for %s: %s\n", contributionType, err.Error())
+	}
+	log.Printf("Completed crawling for %s! Visited %d of %d pages in %d searches: %d inserted, %d updated, %d unchanged, %d skipped, %d duplicates, %d forks, %d blocked, %d failed\n", contributionType, result.PagesVisited, result.Pages, result.Shards, result.Inserted, result.Updated, result.Unchanged, result.Skipped, result.Duplicates, result.Forks, result.Blocked, result.Failed)
+	if result.Truncated {
+		log.Printf("GitHub found %d files for %s but only returns the first %d of a search, so not all of them were visited\n", result.TotalCount, contributionType, github.SearchResultCap)
 	}
-	log.Printf("Completed crawling for %s!\n", activityType)
+	return result, nil
 }
@@ -0,0 +1,52 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all contributions to an items.toml file",
+	Run:   runExport,
+}
+
+// Flags
+var (
+	exportFile string
+	sortBy     string
+)
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportFile, "out", "o", "", "The file to write the items to (defaults to stdout)")
+	exportCmd.Flags().StringVar(&sortBy, "sort", database.SortByType, "The order of the items: type, or stars to put the most popular repositories first")
+}
+
+// runExport is the actual execution of the command
+func runExport(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	var w io.Writer = os.Stdout
+	if len(exportFile) > 0 {
+		file, err := os.Create(exportFile)
+		if err != nil {
+			log.Fatalf("Error while creating %s: %s\n", exportFile, err.Error())
+		}
+		defer file.Close()
+		w = file
+	}
+
+	err := db.ExportItems(w, database.ExportOptions{SortBy: sortBy})
+	if err != nil {
+		log.Fatalf("Error while exporting items: %s\n", err.Error())
+	}
+}
@@ -4,8 +4,11 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/retgits/fdio/database"
+	"github.com/retgits/fdio/lock"
 	"github.com/spf13/cobra"
 )
 
@@ -22,19 +25,21 @@ var (
 	databaseFile string
 	activityType string
 	timeout      float64
+	forceLock    bool
 )
 
 // Queries
 var (
 	statisticsQueries = []string{
 		"select author, count(author) as num from contributions group by author order by num desc limit 5",
-		"select type, count(type) as num from contributions group by type",
+		"select upper(replace(contributiontype, 'flogo:', '')) as type, count(*) as num from contributions group by 1",
 	}
 )
 
 const (
-	// Name of the lock file to prevent two instances of FDIO accessing resources at the same time
-	crawlLockFile = ".crawl"
+	// Suffix added to the database file to get the name of the lock file that prevents two instances of FDIO
+	// writing to the same database at the same time
+	lockFileSuffix = ".lock"
 
 	// Version number of FDIO
 	Version = "0.1.2"
@@ -52,6 +57,31 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().StringVar(&databaseFile, "db", "", "The path to the database (required)")
 	rootCmd.MarkPersistentFlagRequired("db")
+	rootCmd.PersistentFlags().BoolVar(&forceLock, "force", false, "Take over the lock on the database held by another instance of fdio")
 	rootCmd.Version = Version
 	rootCmd.SetVersionTemplate("\nYou're running FDIO version {{.Version}}\n\n")
 }
+
+// mustLock acquires the lock on the database for commands that write to it. If another instance of fdio holds the
+// lock the command exits, unless the --force flag is set. A lock that isn't released because the command exits early
+// is detected as stale by the next command.
+func mustLock() *lock.Lock {
+	l, err := lock.Acquire(databaseFile+lockFileSuffix, forceLock)
+	if err != nil {
+		if _, ok := err.(*lock.LockedError); ok {
+			log.Fatalf("Error: %s. Use --force to take over the lock\n", err.Error())
+		}
+		log.Fatalf("Error while locking the database: %s\n", err.Error())
+	}
+	return l
+}
+
+// mustOpenCurrent opens the database for commands that need its schema to be up to date. If the schema is at a
+// different version than the one fdio works with the command exits with a message that explains how to upgrade it.
+func mustOpenCurrent() *database.Database {
+	db, err := database.OpenCurrentSession(databaseFile)
+	if err != nil {
+		log.Fatalf("Error while opening the database: %s\n", err.Error())
+	}
+	return db
+}
@@ -52,6 +52,11 @@ func (suite *FDIOCommandsTestSuite) TestRunInit() {
 	os.Create("./init.db")
 	res, err = runner(args)
 	assert.NoError(suite.T(), err)
+
+	// An existing database is never overwritten
+	res, err = runner(args)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), res, "./init.db already contains a database")
 }
 
 func (suite *FDIOCommandsTestSuite) TestRunStats() {
@@ -0,0 +1,46 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// forksCmd represents the forks command
+var forksCmd = &cobra.Command{
+	Use:   "forks",
+	Short: "List the descriptors found in forks and how they relate to the upstream repository",
+	Run:   runForks,
+}
+
+// forksQuery lists the forks grouped by the upstream descriptor, with the version of the upstream contribution so
+// forks that diverged can be compared to it
+const forksQuery = `select f.upstream, ifnull(c.version, '') as upstreamversion, f.sourceurl as fork, f.status, f.version, f.lastseen
+	from forks f left join contributions c on c.sourceurl = f.upstream
+	order by f.upstream, f.sourceurl`
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(forksCmd)
+}
+
+// runForks is the actual execution of the command
+func runForks(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	queryOpts := database.QueryOptions{
+		Writer:     os.Stdout,
+		Query:      forksQuery,
+		MergeCells: true,
+		RowLine:    true,
+		Render:     true,
+	}
+	_, err := db.Query(queryOpts)
+	if err != nil {
+		log.Fatalf("Error while listing forks: %s\n", err.Error())
+	}
+}
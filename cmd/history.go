@@ -0,0 +1,63 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the changes to a contribution over time",
+	Run:   runHistory,
+}
+
+// Flags
+var (
+	historySourceURL string
+)
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringVar(&historySourceURL, "source-url", "", "The source url of the contribution (required)")
+	historyCmd.MarkFlagRequired("source-url")
+}
+
+// runHistory is the actual execution of the command
+func runHistory(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	entries, err := db.History(historySourceURL)
+	if err != nil {
+		log.Fatalf("Error while reading the history of %s: %s\n", historySourceURL, err.Error())
+	}
+	if len(entries) == 0 {
+		log.Printf("There is no history for %s\n", historySourceURL)
+		return
+	}
+
+	// Every change is a row per field that changed, the merged cells show which fields changed together
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"changedon", "action", "run", "field", "old", "new"})
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+	table.SetCaption(true, historySourceURL)
+	for _, e := range entries {
+		changedOn := e.ChangedOn.UTC().Format(time.RFC3339)
+		changes := e.Changes()
+		if len(changes) == 0 {
+			table.Append([]string{changedOn, e.Action, e.RunID, "", "", ""})
+		}
+		for _, c := range changes {
+			table.Append([]string{changedOn, e.Action, e.RunID, c.Field, c.Old, c.New})
+		}
+	}
+	table.Render()
+}
@@ -0,0 +1,57 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import contributions from an items.toml file",
+	Run:   runImport,
+}
+
+// Flags
+var (
+	importFile string
+)
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "", "The items.toml file to import (required)")
+	importCmd.MarkFlagRequired("file")
+}
+
+// runImport is the actual execution of the command
+func runImport(cmd *cobra.Command, args []string) {
+	file, err := os.Open(importFile)
+	if err != nil {
+		log.Fatalf("Error while opening %s: %s\n", importFile, err.Error())
+	}
+	defer file.Close()
+
+	itemsFile, err := database.ParseItems(file)
+	if err != nil {
+		log.Fatalf("Error while reading %s: %s\n", importFile, err.Error())
+	}
+
+	db := mustOpenCurrent()
+	db.RunID = database.NewRunID("import", time.Now())
+
+	l := mustLock()
+	defer l.Release()
+
+	inserted, updated, err := db.ImportItems(itemsFile.Items, time.Now())
+	if err != nil {
+		log.Fatalf("Error while importing items: %s\n", err.Error())
+	}
+	log.Printf("Imported %d items from %s (%d inserted, %d updated, %d unchanged)\n", len(itemsFile.Items), importFile, inserted, updated, len(itemsFile.Items)-inserted-updated)
+}
@@ -5,7 +5,6 @@ package cmd
 import (
 	"log"
 	"os"
-	"strings"
 
 	"github.com/retgits/fdio/database"
 	"github.com/spf13/cobra"
@@ -25,11 +24,28 @@ func init() {
 
 // runInit is the actual execution of the command
 func runInit(cmd *cobra.Command, args []string) {
-	_, err := database.OpenSession(databaseFile)
-	if err != nil && !strings.Contains(err.Error(), "no such file or directory") {
+	// The lock is taken before the file is touched, so a database another instance of fdio is using is left alone
+	l := mustLock()
+	defer l.Release()
+
+	// Only a new (or empty) file is initialized, an existing database is never overwritten
+	info, err := os.Stat(databaseFile)
+	switch {
+	case err == nil && info.Size() > 0:
+		l.Release()
+		log.Fatalf("Error: %s already contains a database. Use fdio migrate up to upgrade it\n", databaseFile)
+	case err != nil && !os.IsNotExist(err):
+		l.Release()
 		log.Fatal(err.Error())
+	case err != nil:
+		file, err := os.OpenFile(databaseFile, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			l.Release()
+			log.Fatalf("Error while creating the database: %s\n", err.Error())
+		}
+		file.Close()
 	}
-	os.Create(databaseFile)
+
 	err = database.MustOpenSession(databaseFile).Initialize()
 	if err != nil {
 		log.Fatal(err.Error())
@@ -0,0 +1,47 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "List the problems found in the descriptors of contributions",
+	Run:   runLint,
+}
+
+// lintQuery lists the findings with the name of the contribution they belong to. Descriptors that aren't valid JSON
+// have no contribution, so their name is empty.
+const lintQuery = `select f.sourceurl as url, ifnull(c.name, '') as name, f.rule, f.message
+	from findings f left join contributions c on c.sourceurl = f.sourceurl
+	order by f.sourceurl, f.position`
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+// runLint is the actual execution of the command
+func runLint(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	queryOpts := database.QueryOptions{
+		Writer:     os.Stdout,
+		Query:      lintQuery,
+		MergeCells: true,
+		RowLine:    true,
+		Render:     true,
+	}
+	res, err := db.Query(queryOpts)
+	if err != nil {
+		log.Fatalf("Error while listing findings: %s\n", err.Error())
+	}
+	log.Printf("Found %d problems\n", len(res.Rows))
+}
@@ -0,0 +1,88 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the structure of the database to the version used by fdio",
+}
+
+// migrateUpCmd represents the migrate up command
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Args:  cobra.NoArgs,
+	Run:   runMigrateUp,
+}
+
+// migrateStatusCmd represents the migrate status command
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the migrations and whether they have been applied",
+	Args:  cobra.NoArgs,
+	Run:   runMigrateStatus,
+}
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+}
+
+// runMigrateUp is the actual execution of the up command
+func runMigrateUp(cmd *cobra.Command, args []string) {
+	db := database.MustOpenSession(databaseFile)
+
+	l := mustLock()
+	defer l.Release()
+
+	applied, err := db.Migrate(time.Now())
+	for _, m := range applied {
+		log.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		log.Fatalf("Error while migrating the database: %s\n", err.Error())
+	}
+	log.Printf("Database schema is at version %d\n", database.LatestVersion())
+}
+
+// runMigrateStatus is the actual execution of the status command
+func runMigrateStatus(cmd *cobra.Command, args []string) {
+	db := database.MustOpenSession(databaseFile)
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		log.Fatalf("Error while reading migrations: %s\n", err.Error())
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		log.Fatalf("Error while reading migrations: %s\n", err.Error())
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"version", "description", "appliedon"})
+	table.SetCaption(true, fmt.Sprintf("Database schema is at version %d, fdio uses version %d", version, database.LatestVersion()))
+	for _, m := range migrations {
+		appliedOn := m.AppliedOn
+		if len(appliedOn) == 0 {
+			appliedOn = "pending"
+		}
+		table.Append([]string{strconv.Itoa(m.Version), m.Description, appliedOn})
+	}
+	table.Render()
+}
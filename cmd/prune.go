@@ -0,0 +1,51 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove contributions whose descriptors have been missing for longer than the grace period",
+	Run:   runPrune,
+}
+
+// Flags
+var (
+	gracePeriod time.Duration
+	hide        bool
+)
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().DurationVar(&gracePeriod, "grace", 7*24*time.Hour, "How long a descriptor has to be missing before its contribution is pruned")
+	pruneCmd.Flags().BoolVar(&hide, "hide", false, "Hide the contributions so they are no longer exported instead of removing them")
+}
+
+// runPrune is the actual execution of the command
+func runPrune(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+	db.RunID = database.NewRunID("prune", time.Now())
+
+	l := mustLock()
+	defer l.Release()
+
+	pruned, err := db.Prune(time.Now().Add(-gracePeriod), hide)
+	if err != nil {
+		log.Fatalf("Error while pruning contributions: %s\n", err.Error())
+	}
+
+	if hide {
+		log.Printf("Hid %d contributions that have been missing for more than %s\n", pruned, gracePeriod)
+	} else {
+		log.Printf("Removed %d contributions that have been missing for more than %s\n", pruned, gracePeriod)
+	}
+}
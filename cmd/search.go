@@ -0,0 +1,64 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/retgits/fdio/database"
+	"github.com/spf13/cobra"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <terms>",
+	Short: "Search contributions by name, title, description, ref and author",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runSearch,
+}
+
+// Flags
+var (
+	searchType   string
+	searchAuthor string
+	searchLimit  int
+)
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVar(&searchType, "type", "", "Only show contributions of the type, like activity or trigger")
+	searchCmd.Flags().StringVar(&searchAuthor, "author", "", "Only show contributions of the author")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "The maximum number of contributions to show, 0 shows all")
+}
+
+// runSearch is the actual execution of the command
+func runSearch(cmd *cobra.Command, args []string) {
+	db := mustOpenCurrent()
+
+	terms := strings.Join(args, " ")
+	results, err := db.Search(terms, database.SearchOptions{
+		Type:   searchType,
+		Author: searchAuthor,
+		Limit:  searchLimit,
+	})
+	if err != nil {
+		log.Fatalf("Error while searching: %s\n", err.Error())
+	}
+	if len(results) == 0 {
+		log.Printf("No contributions found for %s\n", terms)
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"name", "type", "author", "version", "description", "url"})
+	table.SetRowLine(true)
+	for _, r := range results {
+		item := r.Item()
+		table.Append([]string{item.Name, item.Type, item.Author, r.Version, item.Description, item.URL})
+	}
+	table.Render()
+}
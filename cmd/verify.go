@@ -0,0 +1,47 @@
+// Package cmd defines and implements command-line commands and flags
+// used by fdio. Commands and flags are implemented using Cobra.
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/retgits/fdio/github"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check whether the descriptors of all contributions still exist on GitHub",
+	Run:   runVerify,
+}
+
+// init registers the command and flags
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVar(&githubRawURL, "raw-url", github.DefaultRawURL, "The URL to download raw file content from GitHub")
+	verifyCmd.Flags().IntVar(&concurrency, "concurrency", github.DefaultConcurrency, "The number of descriptors to check at the same time")
+}
+
+// runVerify is the actual execution of the command
+func runVerify(cmd *cobra.Command, args []string) {
+	// The raw content of public repositories can be downloaded without a token, so it is only used when it's set
+	githubToken := os.Getenv("GITHUB_ACCESS_TOKEN")
+
+	db := mustOpenCurrent()
+
+	l := mustLock()
+	defer l.Release()
+
+	client := github.NewClient(github.ClientOptions{
+		Token:  githubToken,
+		RawURL: githubRawURL,
+	})
+
+	result, err := client.Verify(db, concurrency)
+	if err != nil {
+		log.Fatalf("Error while verifying contributions: %s\n", err.Error())
+	}
+	log.Printf("Verified %d contributions: %d present, %d missing, %d failed\n", result.Checked, result.Present, result.Missing, result.Failed)
+}
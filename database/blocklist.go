@@ -0,0 +1,163 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Kinds of values a blocklist rule can match
+const (
+	// OwnerRule matches the owner of the repository (like retgits)
+	OwnerRule = "owner"
+	// RepoRule matches the full name of the repository (like retgits/flogo-components)
+	RepoRule = "repo"
+	// RefRule matches the ref of the contribution (like github.com/retgits/flogo-components/activity/hello)
+	RefRule = "ref"
+)
+
+// Actions of a blocklist rule
+const (
+	// Deny skips matching contributions when crawling and exporting
+	Deny = "deny"
+	// Allow exempts matching contributions from the deny rules
+	Allow = "allow"
+)
+
+// Rule is an entry on the blocklist. The pattern is a glob (like someone/test-*) that is matched, ignoring case, to
+// the owner, repository or ref of a contribution.
+type Rule struct {
+	Kind    string
+	Pattern string
+	Action  string
+	AddedOn time.Time
+}
+
+// Blocklist is the list of rules that decides which contributions are skipped. Allow rules take precedence over deny
+// rules, so a single repository of an owner that is denied can still be allowed.
+type Blocklist []Rule
+
+// AddRule adds the rule to the blocklist, replacing the action of a rule with the same kind and pattern.
+func (db *Database) AddRule(r Rule, t time.Time) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	_, err := db.DB.Exec("insert into blocklist(kind, pattern, action, addedon) values(?, ?, ?, ?) on conflict(kind, pattern) do update set action=excluded.action, addedon=excluded.addedon",
+		r.Kind, r.Pattern, r.Action, timestamp(t))
+	if err != nil {
+		return fmt.Errorf("error storing rule %s %s: %s", r.Kind, r.Pattern, err.Error())
+	}
+	return nil
+}
+
+// RemoveRule removes the rule with the kind and pattern from the blocklist. It returns false if there is no such rule.
+func (db *Database) RemoveRule(kind string, pattern string) (bool, error) {
+	res, err := db.DB.Exec("delete from blocklist where kind=? and pattern=?", kind, pattern)
+	if err != nil {
+		return false, fmt.Errorf("error removing rule %s %s: %s", kind, pattern, err.Error())
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error removing rule %s %s: %s", kind, pattern, err.Error())
+	}
+	return affected > 0, nil
+}
+
+// Blocklist returns all rules on the blocklist, ordered by kind and pattern.
+func (db *Database) Blocklist() (Blocklist, error) {
+	rows, err := db.DB.Query("select kind, pattern, ifnull(action, ''), addedon from blocklist order by kind, pattern")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading blocklist: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var blocklist Blocklist
+	for rows.Next() {
+		var r Rule
+		var addedOn sql.NullTime
+		if err = rows.Scan(&r.Kind, &r.Pattern, &r.Action, &addedOn); err != nil {
+			return nil, fmt.Errorf("error while reading blocklist: %s", err.Error())
+		}
+		r.AddedOn = addedOn.Time
+		blocklist = append(blocklist, r)
+	}
+
+	return blocklist, rows.Err()
+}
+
+// Blocked returns true if a deny rule matches the repository (like retgits/flogo-components) or the ref, and no allow
+// rule does.
+func (b Blocklist) Blocked(fullName string, ref string) bool {
+	var denied bool
+	for _, r := range b {
+		if !r.matches(fullName, ref) {
+			continue
+		}
+		if r.Action == Allow {
+			return false
+		}
+		denied = true
+	}
+	return denied
+}
+
+// BlockedContribution returns true if the blocklist blocks the contribution. The repository is taken from the
+// contribution or, when it's unknown, from the source url.
+func (b Blocklist) BlockedContribution(c Contribution) bool {
+	fullName := c.Repository
+	if len(fullName) == 0 {
+		if u, err := url.Parse(c.SourceURL); err == nil {
+			parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 3)
+			if len(parts) >= 2 {
+				fullName = parts[0] + "/" + parts[1]
+			}
+		}
+	}
+	return b.Blocked(fullName, c.Ref)
+}
+
+// matches returns true if the rule matches the repository or the ref.
+func (r Rule) matches(fullName string, ref string) bool {
+	var value string
+	switch r.Kind {
+	case OwnerRule:
+		value = strings.SplitN(fullName, "/", 2)[0]
+	case RepoRule:
+		value = fullName
+	case RefRule:
+		value = ref
+	}
+	if len(value) == 0 {
+		return false
+	}
+
+	ok, _ := path.Match(strings.ToLower(r.Pattern), strings.ToLower(value))
+	return ok
+}
+
+// validate checks the kind, action and pattern of the rule.
+func (r Rule) validate() error {
+	switch r.Kind {
+	case OwnerRule, RepoRule, RefRule:
+	default:
+		return fmt.Errorf("unknown kind of rule: %s", r.Kind)
+	}
+
+	switch r.Action {
+	case Allow, Deny:
+	default:
+		return fmt.Errorf("unknown action: %s", r.Action)
+	}
+
+	if _, err := path.Match(r.Pattern, ""); err != nil || len(r.Pattern) == 0 {
+		return fmt.Errorf("invalid pattern: %q", r.Pattern)
+	}
+
+	return nil
+}
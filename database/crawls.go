@@ -0,0 +1,32 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LastCrawl returns the time the last successful crawl for the type of contribution started. The zero time is
+// returned if there hasn't been a successful crawl yet.
+func (db *Database) LastCrawl(contributionType string) (time.Time, error) {
+	var lastCrawl sql.NullTime
+	err := db.DB.Get(&lastCrawl, "select lastcrawl from crawls where contributiontype=?", contributionType)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading last crawl of %s: %s", contributionType, err.Error())
+	}
+
+	return lastCrawl.Time, nil
+}
+
+// SetLastCrawl records the time the last successful crawl for the type of contribution started.
+func (db *Database) SetLastCrawl(contributionType string, t time.Time) error {
+	_, err := db.DB.Exec("insert into crawls(contributiontype, lastcrawl) values(?, ?) on conflict(contributiontype) do update set lastcrawl=excluded.lastcrawl", contributionType, timestamp(t))
+	if err != nil {
+		return fmt.Errorf("error storing last crawl of %s: %s", contributionType, err.Error())
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/olekukonko/tablewriter"
@@ -18,6 +19,10 @@ import (
 type Database struct {
 	File string
 	DB   *sqlx.DB
+
+	// RunID is recorded in the history of the contributions that are changed, so the changes made by a single run of
+	// a command (like a crawl) can be found. See NewRunID.
+	RunID string
 }
 
 // QueryOptions represents the options you can have for a query and how the result will be rendered
@@ -46,6 +51,10 @@ type QueryResponse struct {
 	Rows        [][]string
 	ColumnNames []string
 	Table       *tablewriter.Table
+
+	// Values holds the same rows as Rows, with each value in its Go type. Columns declared as boolean are a bool and
+	// columns declared as timestamp are a time.Time.
+	Values [][]interface{}
 }
 
 // Contributions is a slice of contribution objects
@@ -58,13 +67,32 @@ type Contribution struct {
 	ContributionType string `json:"type"`
 	SourceURL        string
 	Author           string `json:"author"`
-	UploadedOn       string
 	ShowcaseEnabled  bool
 	Description      string `json:"description"`
 	Version          string `json:"version"`
 	Title            string `json:"title"`
 	Homepage         string `json:"homepage"`
 	Legacy           bool
+	Permalink        string
+
+	// UploadedOn is the time the contribution was first discovered, it doesn't change once the contribution is stored.
+	// LastCrawled is the last time the crawl found the contribution and LastChanged the last time any of the fields
+	// that describe the contribution changed.
+	UploadedOn  time.Time
+	LastCrawled time.Time
+	LastChanged time.Time
+
+	// Repository is the full name of the repository the contribution was found in (like retgits/flogo-components),
+	// its details are stored in the repositories table
+	Repository string
+
+	// Status, CheckedOn and MissingSince are set when fdio verify checks whether the descriptor still exists, and
+	// Hidden when fdio prune hides a contribution that has been missing for too long. Storing a contribution doesn't
+	// change them.
+	Status       string
+	CheckedOn    time.Time
+	MissingSince time.Time
+	Hidden       bool
 }
 
 // OpenSession creates a new reference to an SQLite database. If the file cannot be found an exception will be returned.
@@ -94,22 +122,14 @@ func MustOpenSession(file string) *Database {
 }
 
 // Initialize creates the new database structure. This method must be called if you're starting with a brand new database.
-// The sourceurl (github url) is the primary key as there can be only one activity in a location.
+// The sourceurl (github url) is the primary key as there can be only one activity in a location. The contributions table
+// is created as it was in the first version of fdio and all migrations are applied to bring it up to date.
 func (db *Database) Initialize() error {
-	return db.Exec(`create table contributions(
-		ref text, 
-		name text, 
-		contributiontype text, 
-		sourceurl text not null primary key, 
-		author text, 
-		uploadedon text, 
-		showcaseenabled text, 
-		description text, 
-		version text, 
-		title text, 
-		homepage text, 
-		legacy text)
-	`)
+	if err := db.Exec(createContributionsQuery); err != nil {
+		return err
+	}
+	_, err := db.Migrate(time.Now())
+	return err
 }
 
 // Close closes the database and prevents new queries from starting. Close then waits for all queries that have started processing on the server to finish.
@@ -123,16 +143,175 @@ func (db *Database) Exec(query string) error {
 	return err
 }
 
-// UpdateContribution updates the data for activities and triggers in the database,
+// UpsertResult describes what happened to a contribution when it was stored in the database
+type UpsertResult int
+
+const (
+	// Inserted means the contribution did not exist yet and was added
+	Inserted UpsertResult = iota
+	// Updated means the contribution already existed and at least one of its fields changed
+	Updated
+	// Unchanged means the contribution already existed with exactly the same data
+	Unchanged
+)
+
+func (r UpsertResult) String() string {
+	return [...]string{
+		"inserted",
+		"updated",
+		"unchanged",
+	}[r]
+}
+
+const (
+	insertContributionQuery = `insert into contributions(ref, name, contributiontype, sourceurl, author, uploadedon, lastcrawled, lastchanged, showcaseenabled, description, version, title, homepage, legacy, permalink, repository)
+		values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// The update only happens when one of the fields that describe the contribution differs from what is stored, so
+	// the number of affected rows tells whether anything changed. The uploadedon field is never updated, and the
+	// timestamps and the permalink aren't compared as they change on every crawl and with every commit to the
+	// repository. An empty permalink or repository doesn't overwrite the one that is stored. Whether a contribution is
+	// in the showcase isn't part of its descriptor, it's set when the contribution is inserted and after that only
+	// changed by an import.
+	upsertContributionQuery = insertContributionQuery + `
+		on conflict(sourceurl) do update set
+			ref=excluded.ref,
+			name=excluded.name,
+			contributiontype=excluded.contributiontype,
+			author=excluded.author,
+			uploadedon=ifnull(contributions.uploadedon, excluded.uploadedon),
+			lastcrawled=ifnull(excluded.lastcrawled, contributions.lastcrawled),
+			lastchanged=ifnull(excluded.lastchanged, contributions.lastchanged),
+			description=excluded.description,
+			version=excluded.version,
+			title=excluded.title,
+			homepage=excluded.homepage,
+			legacy=excluded.legacy,
+			permalink=coalesce(nullif(excluded.permalink, ''), contributions.permalink),
+			repository=coalesce(nullif(excluded.repository, ''), contributions.repository)
+		where contributions.ref is not excluded.ref
+			or contributions.name is not excluded.name
+			or contributions.contributiontype is not excluded.contributiontype
+			or contributions.author is not excluded.author
+			or contributions.description is not excluded.description
+			or contributions.version is not excluded.version
+			or contributions.title is not excluded.title
+			or contributions.homepage is not excluded.homepage
+			or contributions.legacy is not excluded.legacy
+			or (excluded.repository <> '' and contributions.repository is not excluded.repository)`
+
+	// An item of the items.toml file only has the name, type, description, ref, author and showcase of a
+	// contribution, so importing it over an existing contribution only updates those fields and keeps the rest, like
+	// the version and title the crawl found. The showcase flag is only updated by importShowcaseQuery, when the item
+	// has a valid value for it.
+	importContributionQuery = insertContributionQuery + `
+		on conflict(sourceurl) do update set
+			ref=excluded.ref,
+			name=excluded.name,
+			contributiontype=excluded.contributiontype,
+			author=excluded.author,
+			lastchanged=ifnull(excluded.lastchanged, contributions.lastchanged),
+			description=excluded.description
+		where contributions.ref is not excluded.ref
+			or contributions.name is not excluded.name
+			or contributions.contributiontype is not excluded.contributiontype
+			or contributions.author is not excluded.author
+			or contributions.description is not excluded.description`
+
+	importShowcaseQuery = insertContributionQuery + `
+		on conflict(sourceurl) do update set
+			ref=excluded.ref,
+			name=excluded.name,
+			contributiontype=excluded.contributiontype,
+			author=excluded.author,
+			lastchanged=ifnull(excluded.lastchanged, contributions.lastchanged),
+			showcaseenabled=excluded.showcaseenabled,
+			description=excluded.description
+		where contributions.ref is not excluded.ref
+			or contributions.name is not excluded.name
+			or contributions.contributiontype is not excluded.contributiontype
+			or contributions.author is not excluded.author
+			or contributions.showcaseenabled is not excluded.showcaseenabled
+			or contributions.description is not excluded.description`
+)
+
+// args returns the values of the contribution in the order of the columns used by the insert and upsert statements.
+func (c Contribution) args() []interface{} {
+	return []interface{}{c.Ref, c.Name, c.ContributionType, c.SourceURL, c.Author, timestamp(c.UploadedOn), timestamp(c.LastCrawled), timestamp(c.LastChanged), c.ShowcaseEnabled, c.Description, c.Version, c.Title, c.Homepage, c.Legacy, c.Permalink, c.Repository}
+}
+
+// timestamp returns the time as an ISO timestamp in UTC, the way times are stored in the database. The zero time is
+// stored as null.
+func timestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// UpdateContribution updates the data for activities and triggers in the database, the time the contribution was first
+// discovered is kept.
 func (db *Database) UpdateContribution(c Contribution) error {
-	q := fmt.Sprintf("update contributions set ref=\"%s\", name=\"%s\", contributiontype=\"%s\", author=\"%s\", uploadedon=\"%s\", showcaseenabled=\"%s\", description=\"%s\", version=\"%s\", title=\"%s\", homepage=\"%s\", legacy=\"%s\" where sourceurl=\"%s\"", c.Ref, c.Name, c.ContributionType, c.Author, c.UploadedOn, strconv.FormatBool(c.ShowcaseEnabled), c.Description, c.Version, c.Title, c.Homepage, strconv.FormatBool(c.Legacy), c.SourceURL)
-	return db.Exec(q)
+	return db.changeContribution(c.SourceURL, changedOn(c), func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("update contributions set ref=?, name=?, contributiontype=?, author=?, lastcrawled=ifnull(?, lastcrawled), lastchanged=ifnull(?, lastchanged), showcaseenabled=?, description=?, version=?, title=?, homepage=?, legacy=?, permalink=?, repository=? where sourceurl=?",
+			c.Ref, c.Name, c.ContributionType, c.Author, timestamp(c.LastCrawled), timestamp(c.LastChanged), c.ShowcaseEnabled, c.Description, c.Version, c.Title, c.Homepage, c.Legacy, c.Permalink, c.Repository, c.SourceURL)
+		return err
+	})
 }
 
 // InsertContribution inserts activities and triggers into the database,
 func (db *Database) InsertContribution(c Contribution) error {
-	q := fmt.Sprintf("insert into contributions(ref, name, contributiontype, sourceurl, author, uploadedon, showcaseenabled, description, version, title, homepage, legacy) values(\"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\", \"%s\")", c.Ref, c.Name, c.ContributionType, c.SourceURL, c.Author, c.UploadedOn, strconv.FormatBool(c.ShowcaseEnabled), c.Description, c.Version, c.Title, c.Homepage, strconv.FormatBool(c.Legacy))
-	return db.Exec(q)
+	return db.changeContribution(c.SourceURL, changedOn(c), func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(insertContributionQuery, c.args()...)
+		return err
+	})
+}
+
+// UpsertContribution inserts the contribution or, when a contribution with the same source url already exists, updates
+// it. The result tells whether the contribution was inserted, updated or already stored with the same data. The time it
+// was last crawled is stored either way, the time it was last changed only when the contribution is inserted or updated.
+func (db *Database) UpsertContribution(c Contribution) (UpsertResult, error) {
+	return db.upsertContribution(c, upsertContributionQuery)
+}
+
+// upsertContribution stores the contribution using the upsert query, which decides which fields of an existing
+// contribution are updated.
+func (db *Database) upsertContribution(c Contribution, query string) (UpsertResult, error) {
+	result := Unchanged
+	err := db.changeContribution(c.SourceURL, changedOn(c), func(tx *sqlx.Tx) error {
+		var exists int
+		err := tx.Get(&exists, "select count(*) from contributions where sourceurl=?", c.SourceURL)
+		if err != nil {
+			return fmt.Errorf("error looking up %s: %s", c.SourceURL, err.Error())
+		}
+
+		res, err := tx.Exec(query, c.args()...)
+		if err != nil {
+			return fmt.Errorf("error storing %s: %s", c.SourceURL, err.Error())
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error storing %s: %s", c.SourceURL, err.Error())
+		}
+
+		switch {
+		case exists == 0:
+			result = Inserted
+		case affected > 0:
+			result = Updated
+		case !c.LastCrawled.IsZero():
+			_, err = tx.Exec("update contributions set lastcrawled=? where sourceurl=?", timestamp(c.LastCrawled), c.SourceURL)
+			if err != nil {
+				return fmt.Errorf("error storing %s: %s", c.SourceURL, err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Unchanged, err
+	}
+	return result, nil
 }
 
 // Query run a query on the database and prints the result in a table.
@@ -160,6 +339,7 @@ func (db *Database) Query(opts QueryOptions) (QueryResponse, error) {
 
 	// Prepare a result array
 	var resultArray [][]string
+	var valueArray [][]interface{}
 
 	// Loop over the result
 	for rows.Next() {
@@ -171,6 +351,14 @@ func (db *Database) Query(opts QueryOptions) (QueryResponse, error) {
 				tempStringArray[idx] = strconv.Itoa(int(v))
 			case string:
 				tempStringArray[idx] = v
+			case bool:
+				tempStringArray[idx] = strconv.FormatBool(v)
+			case time.Time:
+				if !v.IsZero() {
+					tempStringArray[idx] = v.UTC().Format(time.RFC3339)
+				}
+			case float64:
+				tempStringArray[idx] = strconv.FormatFloat(v, 'f', -1, 64)
 			case nil:
 				tempStringArray[idx] = ""
 			default:
@@ -179,6 +367,7 @@ func (db *Database) Query(opts QueryOptions) (QueryResponse, error) {
 		}
 		table.Append(tempStringArray)
 		resultArray = append(resultArray, tempStringArray)
+		valueArray = append(valueArray, cols)
 	}
 
 	// Print the table
@@ -188,6 +377,7 @@ func (db *Database) Query(opts QueryOptions) (QueryResponse, error) {
 
 	queryResponse.ColumnNames = colnames
 	queryResponse.Rows = resultArray
+	queryResponse.Values = valueArray
 	queryResponse.Table = table
 
 	return queryResponse, nil
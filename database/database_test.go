@@ -2,6 +2,9 @@
 package database
 
 import (
+	"bytes"
+	"database/sql"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -84,7 +87,7 @@ func (suite *DBQueryTestSuite) TestInsertContrib() {
 		ShowcaseEnabled:  false,
 		SourceURL:        "https://github.com/retgits",
 		Title:            "AwesomeContrib",
-		UploadedOn:       time.Now().Format("2006-01-02"),
+		UploadedOn:       time.Now(),
 		Version:          "0.1.0",
 		Legacy:           true,
 	}
@@ -92,6 +95,74 @@ func (suite *DBQueryTestSuite) TestInsertContrib() {
 	assert.NoError(suite.T(), err)
 }
 
+func (suite *DBQueryTestSuite) TestUpsertContrib() {
+	c := Contribution{
+		Author:           "retgits",
+		ContributionType: "ACTIVITY",
+		Description:      `Says "hello"; drop table contributions; --`,
+		Name:             "hello",
+		Ref:              "github.com/retgits/flogo-components/activity/hello",
+		SourceURL:        "https://github.com/retgits/flogo-components/tree/master/activity/hello/",
+		Title:            `The "Hello" activity`,
+		Version:          "0.1.0",
+	}
+	crawl := func(t time.Time) {
+		c.UploadedOn, c.LastCrawled, c.LastChanged = t, t, t
+	}
+	first := time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC)
+
+	crawl(first)
+	res, err := suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Inserted, res)
+
+	// Crawling the same contribution again only changes the time it was last crawled
+	crawl(first.Add(24 * time.Hour))
+	res, err = suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Unchanged, res)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), first, contributions[0].UploadedOn)
+	assert.Equal(suite.T(), first.Add(24*time.Hour), contributions[0].LastCrawled)
+	assert.Equal(suite.T(), first, contributions[0].LastChanged)
+
+	crawl(first.Add(48 * time.Hour))
+	c.Version = "0.2.0"
+	res, err = suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Updated, res)
+
+	contributions, err = suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 1)
+	c.UploadedOn = first
+	assert.Equal(suite.T(), c, contributions[0])
+
+	// Storing the contribution doesn't change whether it's in the showcase
+	item := c.Item()
+	item.Showcase = "true"
+	_, _, err = suite.db.ImportItems([]Item{item}, first)
+	assert.NoError(suite.T(), err)
+	res, err = suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Unchanged, res)
+	contributions, err = suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), contributions[0].ShowcaseEnabled)
+
+	// Updating the contribution keeps the time it was first discovered as well
+	c.UploadedOn = first.Add(72 * time.Hour)
+	assert.NoError(suite.T(), suite.db.UpdateContribution(c))
+	contributions, err = suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), first, contributions[0].UploadedOn)
+
+	err = suite.db.InsertContribution(c)
+	assert.Error(suite.T(), err)
+}
+
 func (suite *DBQueryTestSuite) TestQuery() {
 	c := Contribution{
 		Author:           "retgits",
@@ -103,7 +174,7 @@ func (suite *DBQueryTestSuite) TestQuery() {
 		ShowcaseEnabled:  false,
 		SourceURL:        "https://github.com/retgits",
 		Title:            "AwesomeContrib",
-		UploadedOn:       time.Now().Format("2006-01-02"),
+		UploadedOn:       time.Now(),
 		Version:          "0.1.0",
 		Legacy:           true,
 	}
@@ -121,6 +192,562 @@ func (suite *DBQueryTestSuite) TestQuery() {
 	res, err := suite.db.Query(o)
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), res)
+
+	o.Query = "select uploadedon, legacy, showcaseenabled from contributions"
+	o.Render = false
+	res, err = suite.db.Query(o)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []interface{}{c.UploadedOn.UTC().Truncate(time.Second), true, false}, res.Values[0])
+	assert.Equal(suite.T(), []string{c.UploadedOn.UTC().Format(time.RFC3339), "true", "false"}, res.Rows[0])
+}
+
+func (suite *DBQueryTestSuite) TestExportItems() {
+	contributions := []Contribution{
+		{
+			Author:           "retgits",
+			ContributionType: "TRIGGER",
+			Description:      "Receive messages from PubNub",
+			Name:             "pubnubsubscriber",
+			Ref:              "github.com/retgits/flogo-components/trigger/pubnubsubscriber",
+			SourceURL:        "https://github.com/retgits/flogo-components/tree/master/trigger/pubnubsubscriber/",
+			UploadedOn:       time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Author:           "retgits",
+			ContributionType: "ACTIVITY",
+			Description:      "Say \"hello\"",
+			Name:             "hello",
+			Ref:              "github.com/retgits/flogo-components/activity/hello",
+			ShowcaseEnabled:  true,
+			SourceURL:        "https://github.com/retgits/flogo-components/tree/master/activity/hello/",
+			UploadedOn:       time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, c := range contributions {
+		suite.db.InsertContribution(c)
+	}
+
+	var buf bytes.Buffer
+	err := suite.db.ExportItems(&buf, ExportOptions{})
+	assert.NoError(suite.T(), err)
+
+	expected := `[[items]]
+name = "hello"
+type = "activity"
+description = "Say \"hello\""
+url = "https://github.com/retgits/flogo-components/tree/master/activity/hello/"
+ref = "github.com/retgits/flogo-components/activity/hello"
+uploadedon = "2020-04-28"
+author = "retgits"
+showcase = "true"
+
+[[items]]
+name = "pubnubsubscriber"
+type = "trigger"
+description = "Receive messages from PubNub"
+url = "https://github.com/retgits/flogo-components/tree/master/trigger/pubnubsubscriber/"
+ref = "github.com/retgits/flogo-components/trigger/pubnubsubscriber"
+uploadedon = "2020-04-28"
+author = "retgits"
+showcase = "false"
+`
+	assert.Equal(suite.T(), expected, buf.String())
+
+	err = suite.db.ExportItems(&buf, ExportOptions{SortBy: "name"})
+	assert.EqualError(suite.T(), err, "unknown sort order: name")
+}
+
+func (suite *DBQueryTestSuite) TestImportItems() {
+	file, err := os.Open("../test/items.toml")
+	assert.NoError(suite.T(), err)
+	defer file.Close()
+
+	itemsFile, err := ParseItems(file)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), itemsFile.Items, 22)
+
+	first := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	inserted, updated, err := suite.db.ImportItems(itemsFile.Items, first)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 22, inserted)
+	assert.Equal(suite.T(), 0, updated)
+
+	itemsFile.Items[0].Description = "An updated description"
+	inserted, updated, err = suite.db.ImportItems(itemsFile.Items, first.Add(time.Hour))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, inserted)
+	assert.Equal(suite.T(), 1, updated)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 22)
+	for _, c := range contributions {
+		if c.SourceURL == itemsFile.Items[0].URL {
+			assert.Equal(suite.T(), "An updated description", c.Description)
+			assert.Equal(suite.T(), "ACTIVITY", c.ContributionType)
+			assert.Equal(suite.T(), first.Add(time.Hour), c.LastChanged)
+		} else {
+			assert.Equal(suite.T(), first, c.LastChanged)
+		}
+	}
+
+	_, _, err = suite.db.ImportItems([]Item{{Name: "nourl"}}, first)
+	assert.EqualError(suite.T(), err, "item 1 (nourl) has no url")
+}
+
+func (suite *DBQueryTestSuite) TestImportKeepsCrawledFields() {
+	c := Contribution{
+		Author:           "retgits",
+		ContributionType: "ACTIVITY",
+		Description:      "Say hello",
+		Name:             "hello",
+		Ref:              "github.com/retgits/flogo-components/activity/hello",
+		SourceURL:        "https://github.com/retgits/flogo-components/tree/master/activity/hello/",
+		Title:            "Hello",
+		Version:          "1.0.0",
+		Homepage:         "https://github.com/retgits/flogo-components",
+		Legacy:           true,
+	}
+	_, err := suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+
+	// The item has the same values for the fields it has, so nothing changes
+	item := c.Item()
+	inserted, updated, err := suite.db.ImportItems([]Item{item}, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, inserted)
+	assert.Equal(suite.T(), 0, updated)
+
+	item.Description = "Say hello to the world"
+	item.Showcase = "true"
+	_, updated, err = suite.db.ImportItems([]Item{item}, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, updated)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 1)
+	assert.Equal(suite.T(), "Say hello to the world", contributions[0].Description)
+	assert.True(suite.T(), contributions[0].ShowcaseEnabled)
+	assert.Equal(suite.T(), "1.0.0", contributions[0].Version)
+	assert.Equal(suite.T(), "Hello", contributions[0].Title)
+	assert.Equal(suite.T(), "https://github.com/retgits/flogo-components", contributions[0].Homepage)
+	assert.True(suite.T(), contributions[0].Legacy)
+
+	// An item without a showcase keeps the showcase flag that is stored
+	item.Showcase = ""
+	_, updated, err = suite.db.ImportItems([]Item{item}, time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, updated)
+	item.Showcase = "yes please"
+	_, _, err = suite.db.ImportItems([]Item{item}, time.Now())
+	assert.NoError(suite.T(), err)
+
+	contributions, err = suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), contributions[0].ShowcaseEnabled)
+
+	entries, err := suite.db.History(c.SourceURL)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 2)
+}
+
+func (suite *DBQueryTestSuite) TestLastCrawl() {
+	t, err := suite.db.LastCrawl("ACTIVITY")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), t.IsZero())
+
+	now := time.Date(2020, 4, 28, 10, 30, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.db.SetLastCrawl("ACTIVITY", now))
+	assert.NoError(suite.T(), suite.db.SetLastCrawl("ACTIVITY", now.Add(time.Hour)))
+
+	t, err = suite.db.LastCrawl("ACTIVITY")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), now.Add(time.Hour), t)
+
+	t, err = suite.db.LastCrawl("TRIGGER")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), t.IsZero())
+}
+
+func (suite *DBQueryTestSuite) TestDescriptor() {
+	d := Descriptor{
+		SourceURL: "https://github.com/project-flogo/contrib/tree/master/trigger/rest/",
+		Category:  "Trigger",
+		Visible:   true,
+		Raw:       `{"name": "flogo-rest"}`,
+		Attributes: []Attribute{
+			{Section: SettingsSection, Position: 0, Name: "port", Type: "int", Required: true},
+			{Section: HandlerSettingsSection, Position: 0, Name: "method", Type: "string", Allowed: `["GET","POST"]`},
+		},
+	}
+	assert.NoError(suite.T(), suite.db.SaveDescriptor(d))
+
+	d.Attributes = d.Attributes[:1]
+	assert.NoError(suite.T(), suite.db.SaveDescriptor(d))
+
+	stored, err := suite.db.Descriptor(d.SourceURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), d, stored)
+
+	_, err = suite.db.Descriptor("https://github.com/retgits")
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *DBQueryTestSuite) TestFindings() {
+	hello := "https://github.com/retgits/flogo-components/tree/master/activity/hello/"
+	rest := "https://github.com/project-flogo/contrib/tree/master/trigger/rest/"
+
+	assert.NoError(suite.T(), suite.db.SaveFindings(rest, []Finding{{Rule: "ref", Message: "ref is missing"}}))
+	assert.NoError(suite.T(), suite.db.SaveFindings(hello, []Finding{
+		{Rule: "version", Message: "version is missing"},
+		{Rule: "name", Message: "name is missing"},
+	}))
+
+	findings, err := suite.db.Findings()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []Finding{
+		{SourceURL: rest, Rule: "ref", Message: "ref is missing"},
+		{SourceURL: hello, Rule: "version", Message: "version is missing"},
+		{SourceURL: hello, Rule: "name", Message: "name is missing"},
+	}, findings)
+
+	// Saving no findings removes the findings of the contribution
+	assert.NoError(suite.T(), suite.db.SaveFindings(hello, nil))
+
+	findings, err = suite.db.Findings()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), findings, 1)
+}
+
+func (suite *DBQueryTestSuite) TestPrune() {
+	for _, name := range []string{"hello", "writetofile", "pubnubsubscriber"} {
+		_, err := suite.db.UpsertContribution(Contribution{Name: name, SourceURL: "https://github.com/retgits/flogo-components/tree/master/" + name + "/"})
+		assert.NoError(suite.T(), err)
+	}
+	hello := "https://github.com/retgits/flogo-components/tree/master/hello/"
+	writetofile := "https://github.com/retgits/flogo-components/tree/master/writetofile/"
+	pubnub := "https://github.com/retgits/flogo-components/tree/master/pubnubsubscriber/"
+	assert.NoError(suite.T(), suite.db.SaveFindings(hello, []Finding{{Rule: "ref", Message: "ref is missing"}}))
+
+	first := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.db.MarkMissing(hello, first))
+	assert.NoError(suite.T(), suite.db.MarkMissing(hello, first.Add(24*time.Hour)))
+	assert.NoError(suite.T(), suite.db.MarkMissing(writetofile, first.Add(10*24*time.Hour)))
+	assert.NoError(suite.T(), suite.db.MarkPresent(pubnub, first))
+
+	// Only hello has been missing for longer than a week
+	cutoff := first.Add(14 * 24 * time.Hour).Add(-7 * 24 * time.Hour)
+
+	pruned, err := suite.db.Prune(cutoff, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, pruned)
+
+	var buf bytes.Buffer
+	assert.NoError(suite.T(), suite.db.ExportItems(&buf, ExportOptions{}))
+	assert.NotContains(suite.T(), buf.String(), hello)
+	assert.Contains(suite.T(), buf.String(), writetofile)
+
+	// A hidden contribution that is found again is shown again
+	assert.NoError(suite.T(), suite.db.MarkPresent(hello, first.Add(30*24*time.Hour)))
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	for _, c := range contributions {
+		if c.SourceURL == hello {
+			assert.False(suite.T(), c.Hidden)
+			assert.Equal(suite.T(), StatusPresent, c.Status)
+			assert.Empty(suite.T(), c.MissingSince)
+		}
+	}
+
+	// Hiding and showing the contribution again are both recorded in the history
+	entries, err := suite.db.History(hello)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 3)
+	assert.Equal(suite.T(), []FieldChange{{Field: "hidden", Old: "false", New: "true"}}, entries[1].Changes())
+	assert.Equal(suite.T(), []FieldChange{{Field: "hidden", Old: "true", New: "false"}}, entries[2].Changes())
+	assert.Equal(suite.T(), first.Add(30*24*time.Hour), entries[2].ChangedOn)
+
+	// Marking a contribution that is shown as present again isn't a change
+	assert.NoError(suite.T(), suite.db.MarkPresent(pubnub, first.Add(30*24*time.Hour)))
+	entries, err = suite.db.History(pubnub)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 1)
+
+	assert.NoError(suite.T(), suite.db.MarkMissing(hello, first))
+
+	pruned, err = suite.db.Prune(cutoff, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, pruned)
+
+	contributions, err = suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 2)
+
+	findings, err := suite.db.Findings()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), findings)
+}
+
+func (suite *DBQueryTestSuite) TestBlocklist() {
+	now := time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.db.AddRule(Rule{Kind: OwnerRule, Pattern: "spammer", Action: Deny}, now))
+	assert.NoError(suite.T(), suite.db.AddRule(Rule{Kind: RepoRule, Pattern: "retgits/test-*", Action: Deny}, now))
+	assert.NoError(suite.T(), suite.db.AddRule(Rule{Kind: RefRule, Pattern: "github.com/retgits/test-flogo/activity/*", Action: Allow}, now))
+	assert.EqualError(suite.T(), suite.db.AddRule(Rule{Kind: "author", Pattern: "spammer", Action: Deny}, now), "unknown kind of rule: author")
+	assert.EqualError(suite.T(), suite.db.AddRule(Rule{Kind: OwnerRule, Pattern: "[", Action: Deny}, now), `invalid pattern: "["`)
+
+	blocklist, err := suite.db.Blocklist()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), blocklist, 3)
+	assert.Equal(suite.T(), Rule{Kind: OwnerRule, Pattern: "spammer", Action: Deny, AddedOn: now}, blocklist[0])
+
+	assert.True(suite.T(), blocklist.Blocked("Spammer/flogo", ""))
+	assert.True(suite.T(), blocklist.Blocked("retgits/test-flogo", "github.com/retgits/test-flogo/trigger/mqtt"))
+	assert.False(suite.T(), blocklist.Blocked("retgits/test-flogo", "github.com/retgits/test-flogo/activity/hello"))
+	assert.False(suite.T(), blocklist.Blocked("retgits/flogo-components", "github.com/retgits/flogo-components/activity/hello"))
+
+	assert.True(suite.T(), blocklist.BlockedContribution(Contribution{SourceURL: "https://github.com/spammer/flogo/tree/master/activity/spam/"}))
+
+	removed, err := suite.db.RemoveRule(OwnerRule, "spammer")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), removed)
+
+	removed, err = suite.db.RemoveRule(OwnerRule, "spammer")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), removed)
+
+	suite.db.InsertContribution(Contribution{Name: "hello", SourceURL: "https://github.com/retgits/test-flogo/tree/master/activity/hello/", Ref: "github.com/retgits/test-flogo/activity/hello"})
+	suite.db.InsertContribution(Contribution{Name: "mqtt", SourceURL: "https://github.com/retgits/test-flogo/tree/master/trigger/mqtt/", Repository: "retgits/test-flogo"})
+
+	var buf bytes.Buffer
+	assert.NoError(suite.T(), suite.db.ExportItems(&buf, ExportOptions{}))
+	assert.Contains(suite.T(), buf.String(), `name = "hello"`)
+	assert.NotContains(suite.T(), buf.String(), `name = "mqtt"`)
+}
+
+func (suite *DBQueryTestSuite) TestHistory() {
+	hello := "https://github.com/retgits/flogo-components/tree/master/activity/hello/"
+	first := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := Contribution{Name: "hello", Ref: "github.com/retgits/flogo-components/activity/hello", SourceURL: hello, Version: "0.1.0", LastChanged: first}
+
+	suite.db.RunID = NewRunID("crawl", first)
+	assert.Equal(suite.T(), "crawl-20200501T120000Z", suite.db.RunID)
+	_, err := suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+
+	// Storing the same contribution again isn't recorded
+	c.LastCrawled = first.Add(time.Hour)
+	_, err = suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+
+	suite.db.RunID = NewRunID("crawl", first.Add(24*time.Hour))
+	c.Version = "0.2.0"
+	c.Description = "Say hello"
+	c.LastChanged = first.Add(24 * time.Hour)
+	_, err = suite.db.UpsertContribution(c)
+	assert.NoError(suite.T(), err)
+
+	suite.db.RunID = ""
+	assert.NoError(suite.T(), suite.db.MarkMissing(hello, first))
+	_, err = suite.db.Prune(first.Add(time.Hour), false)
+	assert.NoError(suite.T(), err)
+
+	entries, err := suite.db.History(hello)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 3)
+
+	assert.Equal(suite.T(), HistoryInsert, entries[0].Action)
+	assert.Equal(suite.T(), "crawl-20200501T120000Z", entries[0].RunID)
+	assert.Equal(suite.T(), first, entries[0].ChangedOn)
+	assert.Nil(suite.T(), entries[0].Old)
+	assert.Equal(suite.T(), "0.1.0", entries[0].New.Version)
+	assert.Equal(suite.T(), []FieldChange{
+		{Field: "name", New: "hello"},
+		{Field: "ref", New: "github.com/retgits/flogo-components/activity/hello"},
+		{Field: "version", New: "0.1.0"},
+	}, entries[0].Changes())
+
+	assert.Equal(suite.T(), HistoryUpdate, entries[1].Action)
+	assert.Equal(suite.T(), "crawl-20200502T120000Z", entries[1].RunID)
+	assert.Equal(suite.T(), []FieldChange{
+		{Field: "version", Old: "0.1.0", New: "0.2.0"},
+		{Field: "description", New: "Say hello"},
+	}, entries[1].Changes())
+
+	assert.Equal(suite.T(), HistoryDelete, entries[2].Action)
+	assert.Empty(suite.T(), entries[2].RunID)
+	assert.Equal(suite.T(), "0.2.0", entries[2].Old.Version)
+	assert.Nil(suite.T(), entries[2].New)
+
+	entries, err = suite.db.History("https://github.com/retgits/unknown")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+func (suite *DBQueryTestSuite) TestSearch() {
+	// The index uses FTS4 no matter how fdio is built
+	var create string
+	err := suite.db.DB.Get(&create, "select sql from sqlite_master where name='contributions_search'")
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), create, "using fts4")
+
+	contributions := []Contribution{
+		{Name: "hello", Title: "Hello", Description: "Say hello to the world", Author: "retgits", ContributionType: "ACTIVITY", SourceURL: "https://github.com/retgits/flogo-components/tree/master/activity/hello/"},
+		{Name: "writetofile", Title: "Write to file", Description: "Write a hello world message to a file", Author: "retgits", ContributionType: "ACTIVITY", SourceURL: "https://github.com/retgits/flogo-components/tree/master/activity/writetofile/"},
+		{Name: "pubnubsubscriber", Title: "PubNub Subscriber", Description: "Receive messages from PubNub", Author: "retgits", ContributionType: "TRIGGER", SourceURL: "https://github.com/retgits/flogo-components/tree/master/trigger/pubnubsubscriber/"},
+		{Name: "log", Title: "Log Message", Description: "Log a message", Author: "project-flogo", ContributionType: "flogo:activity", SourceURL: "https://github.com/project-flogo/contrib/tree/master/activity/log/"},
+	}
+	for _, c := range contributions {
+		_, err = suite.db.UpsertContribution(c)
+		assert.NoError(suite.T(), err)
+	}
+
+	names := func(terms string, opts SearchOptions) []string {
+		results, err := suite.db.Search(terms, opts)
+		assert.NoError(suite.T(), err)
+		var names []string
+		for _, r := range results {
+			assert.True(suite.T(), r.Score > 0)
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	// A match in the name ranks higher than one in the description
+	assert.Equal(suite.T(), []string{"hello", "writetofile"}, names("hello", SearchOptions{}))
+	assert.Equal(suite.T(), []string{"writetofile"}, names("writeto", SearchOptions{}))
+	assert.Equal(suite.T(), []string{"writetofile"}, names("hello file", SearchOptions{}))
+	assert.Equal(suite.T(), []string{"hello"}, names("hello", SearchOptions{Limit: 1}))
+
+	// Filters
+	assert.Equal(suite.T(), []string{"log", "pubnubsubscriber", "writetofile"}, names("message", SearchOptions{}))
+	assert.Equal(suite.T(), []string{"log", "writetofile"}, names("message", SearchOptions{Type: "activity"}))
+	assert.Equal(suite.T(), []string{"pubnubsubscriber"}, names("message", SearchOptions{Type: "flogo:trigger"}))
+	assert.Equal(suite.T(), []string{"log"}, names("message", SearchOptions{Author: "Project-Flogo"}))
+
+	// The index follows updates and deletes, and leaves out hidden and blocked contributions
+	contributions[2].Description = "Receive events from PubNub"
+	_, err = suite.db.UpsertContribution(contributions[2])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"log", "writetofile"}, names("message", SearchOptions{}))
+	assert.Equal(suite.T(), []string{"pubnubsubscriber"}, names("events", SearchOptions{}))
+
+	assert.NoError(suite.T(), suite.db.AddRule(Rule{Kind: OwnerRule, Pattern: "project-flogo", Action: Deny}, time.Now()))
+	assert.Equal(suite.T(), []string{"writetofile"}, names("message", SearchOptions{}))
+
+	first := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.db.MarkMissing(contributions[0].SourceURL, first))
+	assert.NoError(suite.T(), suite.db.MarkMissing(contributions[1].SourceURL, first))
+	_, err = suite.db.Prune(first, true)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), names("hello", SearchOptions{}))
+	assert.NoError(suite.T(), suite.db.MarkPresent(contributions[0].SourceURL, first))
+	_, err = suite.db.Prune(first, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"hello"}, names("hello", SearchOptions{}))
+
+	_, err = suite.db.Search(" -- ", SearchOptions{})
+	assert.EqualError(suite.T(), err, `no words to search for in " -- "`)
+}
+
+func (suite *DBOpsTestSuite) TestMigrate() {
+	db, _ := OpenSession(suite.DatabaseToCreate)
+
+	version, err := db.SchemaVersion()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, version)
+
+	// The earliest versions of fdio created the table without the legacy column and without a primary key
+	err = db.Exec(`create table contributions(ref, name, contributiontype, sourceurl, author, uploadedon, showcaseenabled, description, version, title, homepage);
+		insert into contributions(ref, name, contributiontype, sourceurl) values('old', 'hello', 'flogo:activity', 'https://github.com/retgits/hello');
+		insert into contributions(ref, name, contributiontype, sourceurl, uploadedon, showcaseenabled) values('new', 'hello', 'flogo:activity', 'https://github.com/retgits/hello', '2020-04-28', 'true');
+		insert into contributions(ref, name, contributiontype, sourceurl, uploadedon, showcaseenabled) values('ref', 'log', 'flogo:activity', 'https://github.com/retgits/log', 'now', 'false')`)
+	assert.NoError(suite.T(), err)
+
+	// Later versions stored the booleans and dates of the other tables as text as well
+	err = db.Exec(`create table crawls(contributiontype text not null primary key, lastcrawl text);
+		insert into crawls values('ACTIVITY', '2020-04-30T10:00:00Z');
+		create table repositories(fullname text not null primary key, htmlurl text, description text, stars integer, forks integer, license text, topics text, archived text, defaultbranch text, pushedat text, updatedat text, refreshedon text);
+		insert into repositories(fullname, archived, pushedat, refreshedon) values('retgits/hello', 'true', '2020-04-28T00:00:00Z', '')`)
+	assert.NoError(suite.T(), err)
+
+	version, err = db.SchemaVersion()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, version)
+	assert.EqualError(suite.T(), db.CheckSchema(), fmt.Sprintf("database schema is at version 1 but fdio needs version %d, run fdio migrate up to upgrade it", LatestVersion()))
+
+	_, err = OpenCurrentSession(suite.DatabaseToCreate)
+	assert.Error(suite.T(), err)
+
+	migrations, err := db.Migrations()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), migrations, LatestVersion())
+	assert.Equal(suite.T(), "unknown", migrations[0].AppliedOn)
+	assert.Equal(suite.T(), "", migrations[1].AppliedOn)
+
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	applied, err := db.Migrate(now)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), applied, LatestVersion()-1)
+	assert.Equal(suite.T(), 2, applied[0].Version)
+	assert.NoError(suite.T(), db.CheckSchema())
+
+	// Duplicate source urls are removed, keeping the most recent row, booleans and dates are converted and the data is
+	// usable with the new schema
+	contributions, err := db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 2)
+	assert.Equal(suite.T(), "new", contributions[0].Ref)
+	assert.Equal(suite.T(), time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC), contributions[0].UploadedOn)
+	assert.True(suite.T(), contributions[0].ShowcaseEnabled)
+	assert.True(suite.T(), contributions[1].UploadedOn.IsZero())
+	assert.False(suite.T(), contributions[1].ShowcaseEnabled)
+
+	lastCrawl, err := db.LastCrawl("ACTIVITY")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), time.Date(2020, 4, 30, 10, 0, 0, 0, time.UTC), lastCrawl)
+
+	repositories, err := db.Repositories()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), repositories, 1)
+	assert.True(suite.T(), repositories[0].Archived)
+	assert.Equal(suite.T(), time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC), repositories[0].PushedAt)
+	assert.True(suite.T(), repositories[0].RefreshedOn.IsZero())
+
+	res, err := db.UpsertContribution(Contribution{Ref: "newer", Name: "hello", ContributionType: "flogo:activity", SourceURL: "https://github.com/retgits/hello"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Updated, res)
+
+	migrations, err = db.Migrations()
+	assert.NoError(suite.T(), err)
+	for _, m := range migrations {
+		assert.Equal(suite.T(), "2020-05-01T12:00:00Z", m.AppliedOn)
+	}
+
+	// Migrating an up to date database does nothing
+	applied, err = db.Migrate(now)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), applied)
+
+	err = db.Exec("insert into schema_version(version) values(100)")
+	assert.NoError(suite.T(), err)
+	assert.EqualError(suite.T(), db.CheckSchema(), fmt.Sprintf("database schema is at version 100 which is newer than version %d supported by fdio", LatestVersion()))
+	_, err = db.Migrate(now)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *DBQueryTestSuite) TestSchemaVersion() {
+	version, err := suite.db.SchemaVersion()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), LatestVersion(), version)
+	assert.NoError(suite.T(), suite.db.CheckSchema())
+
+	migrations, err := suite.db.Migrations()
+	assert.NoError(suite.T(), err)
+	for _, m := range migrations {
+		assert.NotEmpty(suite.T(), m.AppliedOn)
+	}
 }
 
 func (suite *DBOpsTestSuite) TestCloseDB() {
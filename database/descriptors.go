@@ -0,0 +1,106 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Sections of a descriptor that contain attributes
+const (
+	SettingsSection        = "settings"
+	InputSection           = "input"
+	OutputSection          = "output"
+	ReplySection           = "reply"
+	HandlerSettingsSection = "handler"
+)
+
+// Descriptor holds the details from the descriptor of a contribution that the contributions table doesn't have
+type Descriptor struct {
+	SourceURL  string
+	Author     string
+	Category   string
+	Visible    bool
+	SmallIcon  string
+	LargeIcon  string
+	Raw        string
+	Attributes []Attribute
+}
+
+// Attribute is a setting, input or output of a contribution. The value and allowed values are stored as JSON.
+type Attribute struct {
+	Section     string
+	Position    int
+	Name        string
+	Type        string
+	Required    bool
+	Value       string
+	Allowed     string
+	Description string
+}
+
+// SaveDescriptor stores the descriptor of a contribution, replacing the descriptor and all attributes that were stored
+// for the same source url before.
+func (db *Database) SaveDescriptor(d Descriptor) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`insert into descriptors(sourceurl, author, category, visible, smallicon, largeicon, raw) values(?, ?, ?, ?, ?, ?, ?)
+		on conflict(sourceurl) do update set author=excluded.author, category=excluded.category, visible=excluded.visible, smallicon=excluded.smallicon, largeicon=excluded.largeicon, raw=excluded.raw`,
+		d.SourceURL, d.Author, d.Category, d.Visible, d.SmallIcon, d.LargeIcon, d.Raw)
+	if err != nil {
+		return fmt.Errorf("error storing descriptor of %s: %s", d.SourceURL, err.Error())
+	}
+
+	if _, err = tx.Exec("delete from attributes where sourceurl=?", d.SourceURL); err != nil {
+		return fmt.Errorf("error removing attributes of %s: %s", d.SourceURL, err.Error())
+	}
+
+	for _, a := range d.Attributes {
+		_, err = tx.Exec("insert into attributes(sourceurl, section, position, name, type, required, value, allowed, description) values(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			d.SourceURL, a.Section, a.Position, a.Name, a.Type, a.Required, a.Value, a.Allowed, a.Description)
+		if err != nil {
+			return fmt.Errorf("error storing attribute %s of %s: %s", a.Name, d.SourceURL, err.Error())
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Descriptor returns the descriptor of the contribution with the source url. The attributes are ordered by section and
+// by their position in the descriptor. If no descriptor is stored, sql.ErrNoRows is returned.
+func (db *Database) Descriptor(sourceURL string) (Descriptor, error) {
+	d := Descriptor{SourceURL: sourceURL}
+
+	err := db.DB.QueryRow("select ifnull(author, ''), ifnull(category, ''), visible, ifnull(smallicon, ''), ifnull(largeicon, ''), ifnull(raw, '') from descriptors where sourceurl=?", sourceURL).
+		Scan(&d.Author, &d.Category, &d.Visible, &d.SmallIcon, &d.LargeIcon, &d.Raw)
+	if err == sql.ErrNoRows {
+		return d, err
+	}
+	if err != nil {
+		return d, fmt.Errorf("error reading descriptor of %s: %s", sourceURL, err.Error())
+	}
+
+	rows, err := db.DB.Query("select section, position, ifnull(name, ''), ifnull(type, ''), required, ifnull(value, ''), ifnull(allowed, ''), ifnull(description, '') from attributes where sourceurl=? order by section, position", sourceURL)
+	if err != nil {
+		return d, fmt.Errorf("error reading attributes of %s: %s", sourceURL, err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attribute
+		if err = rows.Scan(&a.Section, &a.Position, &a.Name, &a.Type, &a.Required, &a.Value, &a.Allowed, &a.Description); err != nil {
+			return d, fmt.Errorf("error reading attributes of %s: %s", sourceURL, err.Error())
+		}
+		d.Attributes = append(d.Attributes, a)
+	}
+
+	return d, rows.Err()
+}
@@ -0,0 +1,60 @@
+// Package database manages storage
+package database
+
+import (
+	"fmt"
+)
+
+// Finding is a problem the validator found in the descriptor of a contribution
+type Finding struct {
+	SourceURL string
+	Rule      string
+	Message   string
+}
+
+// SaveFindings stores the findings for the contribution with the source url, replacing the findings that were stored
+// before. Saving no findings removes the findings of a contribution that has been fixed.
+func (db *Database) SaveFindings(sourceURL string, findings []Finding) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("delete from findings where sourceurl=?", sourceURL); err != nil {
+		return fmt.Errorf("error removing findings of %s: %s", sourceURL, err.Error())
+	}
+
+	for idx, f := range findings {
+		_, err = tx.Exec("insert into findings(sourceurl, position, rule, message) values(?, ?, ?, ?)", sourceURL, idx, f.Rule, f.Message)
+		if err != nil {
+			return fmt.Errorf("error storing finding of %s: %s", sourceURL, err.Error())
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Findings returns all findings stored in the database, ordered by source url and in the order they were found.
+func (db *Database) Findings() ([]Finding, error) {
+	rows, err := db.DB.Query("select sourceurl, ifnull(rule, ''), ifnull(message, '') from findings order by sourceurl, position")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading findings: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var findings []Finding
+	for rows.Next() {
+		var f Finding
+		if err = rows.Scan(&f.SourceURL, &f.Rule, &f.Message); err != nil {
+			return nil, fmt.Errorf("error while reading findings: %s", err.Error())
+		}
+		findings = append(findings, f)
+	}
+
+	return findings, rows.Err()
+}
@@ -0,0 +1,70 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Relation of a fork to the repository it was forked from
+const (
+	// ForkCollapsed means the fork has the same descriptor as the upstream repository, so only the upstream is stored
+	// as a contribution
+	ForkCollapsed = "collapsed"
+	// ForkDiverged means the ref or version in the descriptor of the fork differs from the upstream repository
+	ForkDiverged = "diverged"
+	// ForkUnique means the upstream repository doesn't have the descriptor the fork has
+	ForkUnique = "unique"
+)
+
+// Fork records that a descriptor was found in a fork of another repository
+type Fork struct {
+	// SourceURL is the url of the descriptor in the fork and Upstream the url of the same location in the repository
+	// at the root of the network of forks
+	SourceURL string
+	Upstream  string
+
+	// Status is one of ForkCollapsed, ForkDiverged or ForkUnique
+	Status string
+
+	// Ref and Version are taken from the descriptor in the fork
+	Ref     string
+	Version string
+
+	// LastSeen is the last time a crawl found the descriptor in the fork
+	LastSeen time.Time
+}
+
+// SaveFork records the fork, replacing what was recorded for the same source url before.
+func (db *Database) SaveFork(f Fork, t time.Time) error {
+	_, err := db.DB.Exec(`insert into forks(sourceurl, upstream, status, ref, version, lastseen) values(?, ?, ?, ?, ?, ?)
+		on conflict(sourceurl) do update set upstream=excluded.upstream, status=excluded.status, ref=excluded.ref, version=excluded.version, lastseen=excluded.lastseen`,
+		f.SourceURL, f.Upstream, f.Status, f.Ref, f.Version, timestamp(t))
+	if err != nil {
+		return fmt.Errorf("error storing fork %s: %s", f.SourceURL, err.Error())
+	}
+	return nil
+}
+
+// Forks returns all recorded forks, ordered by the upstream url and the url of the fork.
+func (db *Database) Forks() ([]Fork, error) {
+	rows, err := db.DB.Query("select sourceurl, ifnull(upstream, ''), ifnull(status, ''), ifnull(ref, ''), ifnull(version, ''), lastseen from forks order by upstream, sourceurl")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading forks: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var forks []Fork
+	for rows.Next() {
+		var f Fork
+		var lastSeen sql.NullTime
+		if err = rows.Scan(&f.SourceURL, &f.Upstream, &f.Status, &f.Ref, &f.Version, &lastSeen); err != nil {
+			return nil, fmt.Errorf("error while reading forks: %s", err.Error())
+		}
+		f.LastSeen = lastSeen.Time
+		forks = append(forks, f)
+	}
+
+	return forks, rows.Err()
+}
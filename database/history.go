@@ -0,0 +1,229 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Actions recorded in the history of a contribution
+const (
+	// HistoryInsert means the contribution was stored for the first time
+	HistoryInsert = "insert"
+	// HistoryUpdate means at least one of the fields of the contribution changed
+	HistoryUpdate = "update"
+	// HistoryDelete means the contribution was removed from the database
+	HistoryDelete = "delete"
+)
+
+// HistoryEntry is a single change to a contribution. Old is nil when the contribution was inserted and New is nil
+// when it was deleted.
+type HistoryEntry struct {
+	SourceURL string
+	Action    string
+	Old       *Contribution
+	New       *Contribution
+	RunID     string
+	ChangedOn time.Time
+}
+
+// FieldChange is a field of a contribution that has a different value after a change
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// NewRunID returns an identifier for a run of the command, like crawl-20200501T120000Z, to set as the RunID of the
+// database so the changes it makes can be told apart in the history.
+func NewRunID(command string, t time.Time) string {
+	return fmt.Sprintf("%s-%s", command, t.UTC().Format("20060102T150405Z"))
+}
+
+// contribution reads the contribution with the source url, it returns nil when there is no such contribution.
+func contribution(q sqlx.Queryer, sourceURL string) (*Contribution, error) {
+	rows, err := q.Query(fmt.Sprintf("select %s from contributions where sourceurl=?", contributionColumns), sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	c, err := scanContribution(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// changeContribution runs the change to the contribution with the source url in a transaction and records it in the
+// history. Changes that don't alter any of the fields that describe the contribution, like the time it was last
+// crawled, aren't recorded.
+func (db *Database) changeContribution(sourceURL string, t time.Time, change func(tx *sqlx.Tx) error) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	old, err := contribution(tx, sourceURL)
+	if err != nil {
+		return fmt.Errorf("error looking up %s: %s", sourceURL, err.Error())
+	}
+
+	if err = change(tx); err != nil {
+		return err
+	}
+
+	new, err := contribution(tx, sourceURL)
+	if err != nil {
+		return fmt.Errorf("error looking up %s: %s", sourceURL, err.Error())
+	}
+
+	switch {
+	case old == nil && new != nil:
+		err = db.recordChange(tx, HistoryInsert, nil, new, t)
+	case old != nil && new == nil:
+		err = db.recordChange(tx, HistoryDelete, old, nil, t)
+	case old != nil && len(HistoryEntry{Old: old, New: new}.Changes()) > 0:
+		err = db.recordChange(tx, HistoryUpdate, old, new, t)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %s", err.Error())
+	}
+	return nil
+}
+
+// changedOn returns the time the contribution last changed, or the current time when it isn't set.
+func changedOn(c Contribution) time.Time {
+	if c.LastChanged.IsZero() {
+		return time.Now()
+	}
+	return c.LastChanged
+}
+
+// recordChange stores a change to a contribution in the history, together with the run id of the database.
+func (db *Database) recordChange(tx *sqlx.Tx, action string, old, new *Contribution, t time.Time) error {
+	var sourceURL string
+	if old != nil {
+		sourceURL = old.SourceURL
+	}
+	if new != nil {
+		sourceURL = new.SourceURL
+	}
+
+	oldValue, err := historyValue(old)
+	if err != nil {
+		return fmt.Errorf("error while recording the history of %s: %s", sourceURL, err.Error())
+	}
+	newValue, err := historyValue(new)
+	if err != nil {
+		return fmt.Errorf("error while recording the history of %s: %s", sourceURL, err.Error())
+	}
+
+	var runID interface{}
+	if len(db.RunID) > 0 {
+		runID = db.RunID
+	}
+
+	_, err = tx.Exec("insert into history(sourceurl, action, oldvalue, newvalue, runid, changedon) values(?, ?, ?, ?, ?, ?)",
+		sourceURL, action, oldValue, newValue, runID, timestamp(t))
+	if err != nil {
+		return fmt.Errorf("error while recording the history of %s: %s", sourceURL, err.Error())
+	}
+	return nil
+}
+
+// historyValue returns the contribution as it's stored in the history, which is null when there is no contribution.
+func historyValue(c *Contribution) (interface{}, error) {
+	if c == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// History returns the changes to the contribution with the source url, from the oldest to the most recent.
+func (db *Database) History(sourceURL string) ([]HistoryEntry, error) {
+	rows, err := db.DB.Query("select sourceurl, ifnull(action, ''), oldvalue, newvalue, ifnull(runid, ''), changedon from history where sourceurl=? order by id", sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading history: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var oldValue, newValue sql.NullString
+		var changedOn sql.NullTime
+		if err = rows.Scan(&e.SourceURL, &e.Action, &oldValue, &newValue, &e.RunID, &changedOn); err != nil {
+			return nil, fmt.Errorf("error while reading history: %s", err.Error())
+		}
+		e.ChangedOn = changedOn.Time
+
+		if e.Old, err = unmarshalContribution(oldValue); err != nil {
+			return nil, fmt.Errorf("error while reading history: %s", err.Error())
+		}
+		if e.New, err = unmarshalContribution(newValue); err != nil {
+			return nil, fmt.Errorf("error while reading history: %s", err.Error())
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// unmarshalContribution parses a contribution stored in the history, it returns nil when no contribution is stored.
+func unmarshalContribution(value sql.NullString) (*Contribution, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	var c Contribution
+	if err := json.Unmarshal([]byte(value.String), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Changes returns the fields that describe the contribution and differ between the old and the new values, so for an
+// inserted contribution these are the fields that are set and for a deleted one the fields that were set. The times
+// the contribution was crawled and verified aren't included.
+func (e HistoryEntry) Changes() []FieldChange {
+	old, new := Contribution{}.historyFields(), Contribution{}.historyFields()
+	if e.Old != nil {
+		old = e.Old.historyFields()
+	}
+	if e.New != nil {
+		new = e.New.historyFields()
+	}
+
+	var changes []FieldChange
+	for idx, field := range historyFieldNames {
+		if old[idx] != new[idx] {
+			changes = append(changes, FieldChange{Field: field, Old: old[idx], New: new[idx]})
+		}
+	}
+	return changes
+}
+
+// historyFieldNames are the names of the fields returned by historyFields, in the same order
+var historyFieldNames = []string{"name", "type", "ref", "author", "version", "title", "description", "homepage", "showcase", "legacy", "permalink", "repository", "hidden"}
+
+// historyFields returns the values of the fields that describe the contribution, as shown in its history.
+func (c Contribution) historyFields() []string {
+	return []string{c.Name, c.ContributionType, c.Ref, c.Author, c.Version, c.Title, c.Description, c.Homepage, strconv.FormatBool(c.ShowcaseEnabled), strconv.FormatBool(c.Legacy), c.Permalink, c.Repository, strconv.FormatBool(c.Hidden)}
+}
@@ -0,0 +1,240 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ItemsFile represents the structure of the items.toml file consumed by the showcase and the flogo cli
+type ItemsFile struct {
+	Items []Item `toml:"items"`
+}
+
+// Item is a single entry in the items.toml file
+type Item struct {
+	Name        string `toml:"name"`
+	Type        string `toml:"type"`
+	Description string `toml:"description"`
+	URL         string `toml:"url"`
+	Ref         string `toml:"ref"`
+	UploadedOn  string `toml:"uploadedon"`
+	Author      string `toml:"author"`
+	Showcase    string `toml:"showcase"`
+
+	// Stars, License and Archived are taken from the repository of the contribution. They are left out when the
+	// details of the repository are unknown, and when the repository has no stars or isn't archived.
+	Stars    int64  `toml:"stars,omitzero"`
+	License  string `toml:"license,omitempty"`
+	Archived bool   `toml:"archived,omitempty"`
+}
+
+// uploadedOnLayout is the layout of the date the contribution was uploaded on in the items.toml file
+const uploadedOnLayout = "2006-01-02"
+
+// Orders in which contributions can be exported
+const (
+	// SortByType orders contributions by type, name and url
+	SortByType = "type"
+	// SortByStars orders contributions by the number of stars of their repository, with the most popular first.
+	// Contributions with the same number of stars are ordered by type, name and url.
+	SortByStars = "stars"
+)
+
+// ExportOptions configures how contributions are exported
+type ExportOptions struct {
+	// SortBy is either SortByType or SortByStars, defaults to SortByType
+	SortBy string
+}
+
+// contributionColumns is the list of columns, in order, that is selected when contributions are read from the database.
+// The booleans and timestamps are selected without ifnull so the driver converts them to a bool and a time.Time.
+const contributionColumns = "ifnull(ref, ''), ifnull(name, ''), ifnull(contributiontype, ''), sourceurl, ifnull(author, ''), uploadedon, lastcrawled, lastchanged, showcaseenabled, ifnull(description, ''), ifnull(version, ''), ifnull(title, ''), ifnull(homepage, ''), legacy, ifnull(permalink, ''), ifnull(status, ''), checkedon, missingsince, hidden, ifnull(repository, '')"
+
+// Contributions returns all contributions stored in the database. The contributions are ordered by type, name and
+// source url so the order is the same every time the method is called.
+func (db *Database) Contributions() (Contributions, error) {
+	rows, err := db.DB.Query(fmt.Sprintf("select %s from contributions order by contributiontype, name, sourceurl", contributionColumns))
+	if err != nil {
+		return nil, fmt.Errorf("error while reading contributions: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var contributions Contributions
+
+	for rows.Next() {
+		c, err := scanContribution(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading contributions: %s", err.Error())
+		}
+		contributions = append(contributions, c)
+	}
+
+	return contributions, rows.Err()
+}
+
+// scanContribution reads the contribution in the current row, the row starts with the columns in contributionColumns.
+// The values of any columns that follow are stored in extra.
+func scanContribution(rows *sql.Rows, extra ...interface{}) (Contribution, error) {
+	var c Contribution
+	var uploadedOn, lastCrawled, lastChanged, checkedOn, missingSince sql.NullTime
+	dest := []interface{}{&c.Ref, &c.Name, &c.ContributionType, &c.SourceURL, &c.Author, &uploadedOn, &lastCrawled, &lastChanged, &c.ShowcaseEnabled, &c.Description, &c.Version, &c.Title, &c.Homepage, &c.Legacy, &c.Permalink, &c.Status, &checkedOn, &missingSince, &c.Hidden, &c.Repository}
+	err := rows.Scan(append(dest, extra...)...)
+	c.UploadedOn = uploadedOn.Time
+	c.LastCrawled = lastCrawled.Time
+	c.LastChanged = lastChanged.Time
+	c.CheckedOn = checkedOn.Time
+	c.MissingSince = missingSince.Time
+	return c, err
+}
+
+// ExportItems writes all contributions in the database to the writer using the layout of the items.toml file.
+// Contributions that are hidden or blocked by the blocklist are not exported.
+func (db *Database) ExportItems(w io.Writer, opts ExportOptions) error {
+	contributions, err := db.Contributions()
+	if err != nil {
+		return err
+	}
+
+	blocklist, err := db.Blocklist()
+	if err != nil {
+		return err
+	}
+
+	repositories, err := db.Repositories()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]Repository, len(repositories))
+	for _, r := range repositories {
+		byName[r.FullName] = r
+	}
+
+	itemsFile := ItemsFile{Items: make([]Item, 0, len(contributions))}
+	for _, c := range contributions {
+		if c.Hidden || blocklist.BlockedContribution(c) {
+			continue
+		}
+		item := c.Item()
+		if r, ok := byName[c.Repository]; ok {
+			item.Stars = r.Stars
+			item.License = r.License
+			item.Archived = r.Archived
+		}
+		itemsFile.Items = append(itemsFile.Items, item)
+	}
+
+	switch opts.SortBy {
+	case "", SortByType:
+		// Contributions are already ordered by type, name and url
+	case SortByStars:
+		sort.SliceStable(itemsFile.Items, func(i, j int) bool {
+			return itemsFile.Items[i].Stars > itemsFile.Items[j].Stars
+		})
+	default:
+		return fmt.Errorf("unknown sort order: %s", opts.SortBy)
+	}
+
+	enc := toml.NewEncoder(w)
+	enc.Indent = ""
+	if err := enc.Encode(itemsFile); err != nil {
+		return fmt.Errorf("error while encoding items: %s", err.Error())
+	}
+
+	return nil
+}
+
+// ParseItems reads an items.toml file from the reader.
+func ParseItems(r io.Reader) (ItemsFile, error) {
+	var itemsFile ItemsFile
+	if _, err := toml.DecodeReader(r, &itemsFile); err != nil {
+		return itemsFile, fmt.Errorf("error while decoding items: %s", err.Error())
+	}
+	return itemsFile, nil
+}
+
+// ImportItems stores the items in the database. Items are matched to existing contributions using their url, which is
+// the source url of the contribution, so existing contributions are updated and new ones are inserted. Only the fields
+// an item has (name, type, description, ref, author and showcase) are updated. The number of
+// inserted and updated contributions is returned, items that match a contribution exactly are not counted. The time is
+// recorded as the time the inserted and updated contributions last changed.
+func (db *Database) ImportItems(items []Item, t time.Time) (inserted int, updated int, err error) {
+	for idx, item := range items {
+		if len(item.URL) == 0 {
+			return 0, 0, fmt.Errorf("item %d (%s) has no url", idx+1, item.Name)
+		}
+	}
+
+	for _, item := range items {
+		c := item.Contribution()
+		c.LastChanged = t
+
+		// An item without a valid showcase (like showcase = "") keeps the showcase flag that is stored
+		query := importContributionQuery
+		if _, err := strconv.ParseBool(item.Showcase); err == nil {
+			query = importShowcaseQuery
+		}
+		res, err := db.upsertContribution(c, query)
+		if err != nil {
+			return inserted, updated, err
+		}
+		switch res {
+		case Inserted:
+			inserted++
+		case Updated:
+			updated++
+		}
+	}
+
+	return inserted, updated, nil
+}
+
+// Contribution converts the entry of the items.toml file into a contribution. The type is stored in uppercase, the
+// same way the crawler stores it (so "activity" becomes "ACTIVITY"). The upload date is either a date or a timestamp,
+// other values are ignored.
+func (i Item) Contribution() Contribution {
+	showcase, _ := strconv.ParseBool(i.Showcase)
+	var uploadedOn time.Time
+	for _, layout := range []string{uploadedOnLayout, time.RFC3339} {
+		if t, err := time.Parse(layout, i.UploadedOn); err == nil {
+			uploadedOn = t
+			break
+		}
+	}
+	return Contribution{
+		Author:           i.Author,
+		ContributionType: strings.ToUpper(i.Type),
+		Description:      i.Description,
+		Name:             i.Name,
+		Ref:              i.Ref,
+		ShowcaseEnabled:  showcase,
+		SourceURL:        i.URL,
+		UploadedOn:       uploadedOn,
+	}
+}
+
+// Item converts the contribution into an entry of the items.toml file. The type of the contribution is written in
+// lowercase without the "flogo:" prefix (so "ACTIVITY" and "flogo:activity" both become "activity").
+func (c Contribution) Item() Item {
+	var uploadedOn string
+	if !c.UploadedOn.IsZero() {
+		uploadedOn = c.UploadedOn.UTC().Format(uploadedOnLayout)
+	}
+	return Item{
+		Name:        c.Name,
+		Type:        strings.TrimPrefix(strings.ToLower(c.ContributionType), "flogo:"),
+		Description: c.Description,
+		URL:         c.SourceURL,
+		Ref:         c.Ref,
+		UploadedOn:  uploadedOn,
+		Author:      c.Author,
+		Showcase:    strconv.FormatBool(c.ShowcaseEnabled),
+	}
+}
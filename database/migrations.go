@@ -0,0 +1,541 @@
+// Package database manages storage
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migration is a single, numbered change to the structure of the database. Migrations are applied in order and each
+// one is applied exactly once, the versions that are applied are recorded in the schema_version table.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sqlx.Tx) error
+}
+
+// MigrationStatus describes a migration and whether it has been applied to the database
+type MigrationStatus struct {
+	Version     int
+	Description string
+
+	// AppliedOn is the time the migration was applied, or empty when it is still pending
+	AppliedOn string
+}
+
+// createContributionsQuery is the contributions table as it was created by the first version of fdio. Every change
+// after that is made by one of the migrations.
+const createContributionsQuery = `create table contributions(
+	ref text,
+	name text,
+	contributiontype text,
+	sourceurl text not null primary key,
+	author text,
+	uploadedon text,
+	showcaseenabled text,
+	description text,
+	version text,
+	title text,
+	homepage text,
+	legacy text)`
+
+// migrations is the ordered list of changes to the database. Never change a migration that has been released, add a
+// new one to the end of the list instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Create the contributions table",
+		up:          statements(createContributionsQuery),
+	},
+	{
+		version:     2,
+		description: "Add permalinks and make the source url unique",
+		up: func(tx *sqlx.Tx) error {
+			// Databases created by the earliest versions of fdio don't have the legacy column and have no primary
+			// key, so duplicate source urls are removed (keeping the most recent row) before the index is created
+			if err := addColumns(tx, "contributions", "legacy", "permalink"); err != nil {
+				return err
+			}
+			return statements(
+				"delete from contributions where rowid not in (select max(rowid) from contributions group by sourceurl)",
+				"create unique index if not exists contributions_sourceurl on contributions(sourceurl)",
+			)(tx)
+		},
+	},
+	{
+		version:     3,
+		description: "Add the crawls table",
+		up: statements(`create table if not exists crawls(
+			contributiontype text not null primary key,
+			lastcrawl text)`),
+	},
+	{
+		version:     4,
+		description: "Add the descriptors and attributes tables",
+		up: statements(`create table if not exists descriptors(
+			sourceurl text not null primary key,
+			author text,
+			category text,
+			visible text,
+			smallicon text,
+			largeicon text,
+			raw text)`,
+			`create table if not exists attributes(
+			sourceurl text not null,
+			section text not null,
+			position integer not null,
+			name text,
+			type text,
+			required text,
+			value text,
+			allowed text,
+			description text,
+			primary key(sourceurl, section, position))`),
+	},
+	{
+		version:     5,
+		description: "Add the findings table",
+		up: statements(`create table if not exists findings(
+			sourceurl text not null,
+			position integer not null,
+			rule text,
+			message text,
+			primary key(sourceurl, position))`),
+	},
+	{
+		version:     6,
+		description: "Add the verification status of contributions",
+		up: func(tx *sqlx.Tx) error {
+			return addColumns(tx, "contributions", "status", "checkedon", "missingsince", "hidden")
+		},
+	},
+	{
+		version:     7,
+		description: "Add the forks table",
+		up: statements(`create table if not exists forks(
+			sourceurl text not null primary key,
+			upstream text,
+			status text,
+			ref text,
+			version text,
+			lastseen text)`),
+	},
+	{
+		version:     8,
+		description: "Add the repositories table",
+		up: func(tx *sqlx.Tx) error {
+			if err := addColumns(tx, "contributions", "repository"); err != nil {
+				return err
+			}
+			return statements(`create table if not exists repositories(
+				fullname text not null primary key,
+				htmlurl text,
+				description text,
+				stars integer,
+				forks integer,
+				license text,
+				topics text,
+				archived text,
+				defaultbranch text,
+				pushedat text,
+				updatedat text,
+				refreshedon text)`)(tx)
+		},
+	},
+	{
+		version:     9,
+		description: "Add the blocklist table",
+		up: statements(`create table if not exists blocklist(
+			kind text not null,
+			pattern text not null,
+			action text,
+			addedon text,
+			primary key(kind, pattern))`),
+	},
+	{
+		version:     10,
+		description: "Store booleans as integers and dates as ISO timestamps",
+		// SQLite can't change the type of a column, so the table is created again and the data is copied over. The
+		// booleans were stored as "true" and "false", dates that don't start with a year, month and day are dropped.
+		up: statements(`create table contributions_typed(
+			ref text,
+			name text,
+			contributiontype text,
+			sourceurl text not null primary key,
+			author text,
+			uploadedon timestamp,
+			showcaseenabled boolean not null default 0,
+			description text,
+			version text,
+			title text,
+			homepage text,
+			legacy boolean not null default 0,
+			permalink text,
+			status text,
+			checkedon timestamp,
+			missingsince timestamp,
+			hidden boolean not null default 0,
+			repository text)`,
+			`insert into contributions_typed
+			select ref, name, contributiontype, sourceurl, author, `+isoTimestamp("uploadedon")+`, `+integerBoolean("showcaseenabled")+`,
+				description, version, title, homepage, `+integerBoolean("legacy")+`, permalink, status, `+isoTimestamp("checkedon")+`,
+				`+isoTimestamp("missingsince")+`, `+integerBoolean("hidden")+`, repository
+			from contributions`,
+			"drop table contributions",
+			"alter table contributions_typed rename to contributions"),
+	},
+	{
+		version:     11,
+		description: "Track when contributions were last crawled and last changed",
+		// Until now uploadedon was set on every crawl, so it's the best guess for both
+		up: statements("alter table contributions add column lastcrawled timestamp",
+			"alter table contributions add column lastchanged timestamp",
+			"update contributions set lastcrawled=uploadedon, lastchanged=uploadedon"),
+	},
+	{
+		version:     12,
+		description: "Add the history table",
+		up: statements(`create table if not exists history(
+			id integer primary key autoincrement,
+			sourceurl text not null,
+			action text,
+			oldvalue text,
+			newvalue text,
+			runid text,
+			changedon timestamp)`,
+			"create index if not exists history_sourceurl on history(sourceurl)"),
+	},
+	{
+		version:     13,
+		description: "Add the full-text search index",
+		up: func(tx *sqlx.Tx) error {
+			// The index uses FTS4 instead of FTS5, which the SQLite driver only includes when fdio is built with
+			// -tags sqlite_fts5. Every build supports FTS4, so a database can be used by any fdio binary no matter
+			// which one migrated it.
+			create := "create virtual table contributions_search using fts4(sourceurl, name, title, description, ref, author, notindexed=sourceurl)"
+			// The index keeps its own copy of the text, the triggers keep it in sync with the contributions
+			return statements(create,
+				`insert into contributions_search(sourceurl, name, title, description, ref, author)
+				select sourceurl, ifnull(name, ''), ifnull(title, ''), ifnull(description, ''), ifnull(ref, ''), ifnull(author, '') from contributions`,
+				`create trigger contributions_search_insert after insert on contributions begin
+					insert into contributions_search(sourceurl, name, title, description, ref, author)
+					values(new.sourceurl, ifnull(new.name, ''), ifnull(new.title, ''), ifnull(new.description, ''), ifnull(new.ref, ''), ifnull(new.author, ''));
+				end`,
+				`create trigger contributions_search_update after update of sourceurl, name, title, description, ref, author on contributions begin
+					delete from contributions_search where sourceurl=old.sourceurl;
+					insert into contributions_search(sourceurl, name, title, description, ref, author)
+					values(new.sourceurl, ifnull(new.name, ''), ifnull(new.title, ''), ifnull(new.description, ''), ifnull(new.ref, ''), ifnull(new.author, ''));
+				end`,
+				`create trigger contributions_search_delete after delete on contributions begin
+					delete from contributions_search where sourceurl=old.sourceurl;
+				end`)(tx)
+		},
+	},
+	{
+		version:     14,
+		description: "Store the booleans and dates of the other tables as integers and ISO timestamps",
+		// Like version 10 did for the contributions, the tables are created again and the data is copied over
+		up: statements(`create table crawls_typed(
+			contributiontype text not null primary key,
+			lastcrawl timestamp)`,
+			"insert into crawls_typed select contributiontype, "+isoTimestamp("lastcrawl")+" from crawls",
+			"drop table crawls",
+			"alter table crawls_typed rename to crawls",
+			`create table descriptors_typed(
+			sourceurl text not null primary key,
+			author text,
+			category text,
+			visible boolean not null default 0,
+			smallicon text,
+			largeicon text,
+			raw text)`,
+			"insert into descriptors_typed select sourceurl, author, category, "+integerBoolean("visible")+", smallicon, largeicon, raw from descriptors",
+			"drop table descriptors",
+			"alter table descriptors_typed rename to descriptors",
+			`create table attributes_typed(
+			sourceurl text not null,
+			section text not null,
+			position integer not null,
+			name text,
+			type text,
+			required boolean not null default 0,
+			value text,
+			allowed text,
+			description text,
+			primary key(sourceurl, section, position))`,
+			"insert into attributes_typed select sourceurl, section, position, name, type, "+integerBoolean("required")+", value, allowed, description from attributes",
+			"drop table attributes",
+			"alter table attributes_typed rename to attributes",
+			`create table forks_typed(
+			sourceurl text not null primary key,
+			upstream text,
+			status text,
+			ref text,
+			version text,
+			lastseen timestamp)`,
+			"insert into forks_typed select sourceurl, upstream, status, ref, version, "+isoTimestamp("lastseen")+" from forks",
+			"drop table forks",
+			"alter table forks_typed rename to forks",
+			`create table repositories_typed(
+			fullname text not null primary key,
+			htmlurl text,
+			description text,
+			stars integer,
+			forks integer,
+			license text,
+			topics text,
+			archived boolean not null default 0,
+			defaultbranch text,
+			pushedat timestamp,
+			updatedat timestamp,
+			refreshedon timestamp)`,
+			`insert into repositories_typed
+			select fullname, htmlurl, description, stars, forks, license, topics, `+integerBoolean("archived")+`, defaultbranch,
+				`+isoTimestamp("pushedat")+`, `+isoTimestamp("updatedat")+`, `+isoTimestamp("refreshedon")+`
+			from repositories`,
+			"drop table repositories",
+			"alter table repositories_typed rename to repositories",
+			`create table blocklist_typed(
+			kind text not null,
+			pattern text not null,
+			action text,
+			addedon timestamp,
+			primary key(kind, pattern))`,
+			"insert into blocklist_typed select kind, pattern, action, "+isoTimestamp("addedon")+" from blocklist",
+			"drop table blocklist",
+			"alter table blocklist_typed rename to blocklist"),
+	},
+}
+
+// isoTimestamp returns the expression that converts the date in the text column to an ISO timestamp in UTC, or null
+// when the column doesn't hold a date.
+func isoTimestamp(column string) string {
+	return fmt.Sprintf("case when %[1]s glob '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]*' then strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', %[1]s) end", column)
+}
+
+// integerBoolean returns the expression that converts the boolean stored as text in the column to 1 or 0.
+func integerBoolean(column string) string {
+	return fmt.Sprintf("case lower(%s) when 'true' then 1 when '1' then 1 else 0 end", column)
+}
+
+// statements returns a migration step that executes the statements in order.
+func statements(queries ...string) func(tx *sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		for _, query := range queries {
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns adds text columns to the table, columns that already exist are skipped. SQLite has no "add column if not
+// exists" so the existing columns are read from the table info first.
+func addColumns(tx *sqlx.Tx, table string, columns ...string) error {
+	var existing []struct {
+		CID          int         `db:"cid"`
+		Name         string      `db:"name"`
+		Type         string      `db:"type"`
+		NotNull      int         `db:"notnull"`
+		DefaultValue interface{} `db:"dflt_value"`
+		PK           int         `db:"pk"`
+	}
+	if err := tx.Select(&existing, fmt.Sprintf("pragma table_info(%s)", table)); err != nil {
+		return err
+	}
+
+	exists := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		exists[c.Name] = true
+	}
+
+	for _, column := range columns {
+		if exists[column] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("alter table %s add column %s text", table, column)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestVersion returns the version of the database schema this version of fdio works with.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// tableExists returns whether the table exists in the database.
+func (db *Database) tableExists(table string) (bool, error) {
+	var count int
+	err := db.DB.Get(&count, "select count(*) from sqlite_master where type='table' and name=?", table)
+	return count > 0, err
+}
+
+// SchemaVersion returns the version of the database schema. Databases created before migrations were introduced have
+// no schema_version table, they are at version 1 when they have a contributions table and at version 0 otherwise.
+func (db *Database) SchemaVersion() (int, error) {
+	versioned, err := db.tableExists("schema_version")
+	if err != nil {
+		return 0, fmt.Errorf("error while reading schema version: %s", err.Error())
+	}
+	if versioned {
+		var version int
+		if err := db.DB.Get(&version, "select ifnull(max(version), 0) from schema_version"); err != nil {
+			return 0, fmt.Errorf("error while reading schema version: %s", err.Error())
+		}
+		return version, nil
+	}
+
+	initialized, err := db.tableExists("contributions")
+	if err != nil {
+		return 0, fmt.Errorf("error while reading schema version: %s", err.Error())
+	}
+	if initialized {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// CheckSchema returns an error when the database schema isn't at the version this version of fdio works with, so
+// commands don't read or write a database they don't understand.
+func (db *Database) CheckSchema() error {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version < LatestVersion():
+		return fmt.Errorf("database schema is at version %d but fdio needs version %d, run fdio migrate up to upgrade it", version, LatestVersion())
+	case version > LatestVersion():
+		return fmt.Errorf("database schema is at version %d which is newer than version %d supported by fdio", version, LatestVersion())
+	}
+	return nil
+}
+
+// OpenCurrentSession is like OpenSession but also returns an error when the database schema isn't up to date.
+func OpenCurrentSession(file string) (*Database, error) {
+	db, err := OpenSession(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.CheckSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate applies all pending migrations, in order, and returns the migrations that were applied. Each migration runs
+// in its own transaction together with the update of the schema_version table, so a migration that fails leaves the
+// database at the previous version.
+func (db *Database) Migrate(t time.Time) ([]MigrationStatus, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestVersion() {
+		return nil, fmt.Errorf("database schema is at version %d which is newer than version %d supported by fdio", version, LatestVersion())
+	}
+
+	_, err = db.DB.Exec(`create table if not exists schema_version(
+		version integer not null primary key,
+		description text,
+		appliedon text)`)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating schema_version table: %s", err.Error())
+	}
+
+	// A database created before migrations were introduced already has the first version, which is recorded so it
+	// isn't applied again
+	if version == 1 {
+		_, err = db.DB.Exec("insert or ignore into schema_version(version, description, appliedon) values(?, ?, ?)", migrations[0].version, migrations[0].description, t.Format(time.RFC3339))
+		if err != nil {
+			return nil, fmt.Errorf("error while recording schema version: %s", err.Error())
+		}
+	}
+
+	var applied []MigrationStatus
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := db.apply(m, t); err != nil {
+			return applied, err
+		}
+		applied = append(applied, MigrationStatus{Version: m.version, Description: m.description, AppliedOn: t.Format(time.RFC3339)})
+	}
+
+	return applied, nil
+}
+
+// apply runs a single migration and records its version.
+func (db *Database) apply(m migration, t time.Time) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("error while applying migration %d (%s): %s", m.version, m.description, err.Error())
+	}
+
+	_, err = tx.Exec("insert into schema_version(version, description, appliedon) values(?, ?, ?)", m.version, m.description, t.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error while recording schema version %d: %s", m.version, err.Error())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %s", err.Error())
+	}
+	return nil
+}
+
+// Migrations returns all migrations known to fdio and when they were applied to the database.
+func (db *Database) Migrations() ([]MigrationStatus, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedOn := make(map[int]string)
+	versioned, err := db.tableExists("schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading migrations: %s", err.Error())
+	}
+	if versioned {
+		rows, err := db.DB.Query("select version, ifnull(appliedon, '') from schema_version")
+		if err != nil {
+			return nil, fmt.Errorf("error while reading migrations: %s", err.Error())
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var v int
+			var on string
+			if err := rows.Scan(&v, &on); err != nil {
+				return nil, fmt.Errorf("error while reading migrations: %s", err.Error())
+			}
+			appliedOn[v] = on
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error while reading migrations: %s", err.Error())
+		}
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Description: m.description, AppliedOn: appliedOn[m.version]}
+		// Databases that were created before migrations were introduced are at the first version without a record
+		if s.AppliedOn == "" && m.version <= version && !versioned {
+			s.AppliedOn = "unknown"
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
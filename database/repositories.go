@@ -0,0 +1,75 @@
+// Package database manages storage
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NoLicense is stored as the license of a repository in which GitHub didn't find a license
+const NoLicense = "none"
+
+// Repository holds the details of a GitHub repository that contributions were found in
+type Repository struct {
+	FullName    string
+	HTMLURL     string
+	Description string
+	Stars       int64
+	Forks       int64
+
+	// License is the SPDX identifier of the license (like MIT), or NoLicense if the repository doesn't have one
+	License string
+	Topics  []string
+
+	Archived      bool
+	DefaultBranch string
+	PushedAt      time.Time
+	UpdatedAt     time.Time
+
+	// RefreshedOn is the last time the details were fetched from GitHub
+	RefreshedOn time.Time
+}
+
+// SaveRepository stores the details of the repository, replacing the details that were stored before.
+func (db *Database) SaveRepository(r Repository, t time.Time) error {
+	_, err := db.DB.Exec(`insert into repositories(fullname, htmlurl, description, stars, forks, license, topics, archived, defaultbranch, pushedat, updatedat, refreshedon) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		on conflict(fullname) do update set htmlurl=excluded.htmlurl, description=excluded.description, stars=excluded.stars, forks=excluded.forks, license=excluded.license, topics=excluded.topics,
+			archived=excluded.archived, defaultbranch=excluded.defaultbranch, pushedat=excluded.pushedat, updatedat=excluded.updatedat, refreshedon=excluded.refreshedon`,
+		r.FullName, r.HTMLURL, r.Description, r.Stars, r.Forks, r.License, strings.Join(r.Topics, ","), r.Archived, r.DefaultBranch, timestamp(r.PushedAt), timestamp(r.UpdatedAt), timestamp(t))
+	if err != nil {
+		return fmt.Errorf("error storing repository %s: %s", r.FullName, err.Error())
+	}
+	return nil
+}
+
+// Repositories returns the details of all repositories, ordered by their full name.
+func (db *Database) Repositories() ([]Repository, error) {
+	rows, err := db.DB.Query(`select fullname, ifnull(htmlurl, ''), ifnull(description, ''), ifnull(stars, 0), ifnull(forks, 0), ifnull(license, ''), ifnull(topics, ''), archived,
+		ifnull(defaultbranch, ''), pushedat, updatedat, refreshedon from repositories order by fullname`)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading repositories: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var repositories []Repository
+	for rows.Next() {
+		var r Repository
+		var topics string
+		var pushedAt, updatedAt, refreshedOn sql.NullTime
+		err = rows.Scan(&r.FullName, &r.HTMLURL, &r.Description, &r.Stars, &r.Forks, &r.License, &topics, &r.Archived, &r.DefaultBranch, &pushedAt, &updatedAt, &refreshedOn)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading repositories: %s", err.Error())
+		}
+		if len(topics) > 0 {
+			r.Topics = strings.Split(topics, ",")
+		}
+		r.PushedAt = pushedAt.Time
+		r.UpdatedAt = updatedAt.Time
+		r.RefreshedOn = refreshedOn.Time
+		repositories = append(repositories, r)
+	}
+
+	return repositories, rows.Err()
+}
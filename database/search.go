@@ -0,0 +1,151 @@
+// Package database manages storage
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchWeights are the weights of the columns of the search index when results are ranked, in the order of the
+// columns: sourceurl (which isn't indexed), name, title, description, ref and author. A match in the name counts the
+// most.
+var searchWeights = []float64{0, 10, 5, 1, 2, 2}
+
+// SearchOptions filters the results of a search
+type SearchOptions struct {
+	// Type only returns contributions of the type (like activity or trigger), ignoring case and the "flogo:" prefix
+	Type string
+
+	// Author only returns contributions of the author, ignoring case
+	Author string
+
+	// Limit is the maximum number of results, 0 returns all results
+	Limit int
+}
+
+// SearchResult is a contribution that matches the search terms, with a higher score for a better match
+type SearchResult struct {
+	Contribution
+	Score float64
+}
+
+// searchQuery turns the terms into a full-text query that matches contributions with all words of the terms, where
+// every word can be the start of a longer word (so "writeto" finds "writetofile"). Everything but
+// letters and digits is ignored, so the terms can't be used to write a query in the syntax of the search index.
+func searchQuery(terms string) string {
+	words := strings.FieldsFunc(terms, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for idx := range words {
+		words[idx] = words[idx] + "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// Search returns the contributions whose name, title, description, ref or author match all the words in the terms,
+// with the best matches first. Contributions that are hidden or blocked by the blocklist are left out, the same way
+// they are left out of the export.
+func (db *Database) Search(terms string, opts SearchOptions) ([]SearchResult, error) {
+	query := searchQuery(terms)
+	if len(query) == 0 {
+		return nil, fmt.Errorf("no words to search for in %q", terms)
+	}
+
+	indexed, err := db.tableExists("contributions_search")
+	if err != nil {
+		return nil, err
+	}
+	if !indexed {
+		return nil, fmt.Errorf("the database has no search index, run fdio migrate up to create it")
+	}
+
+	// The FTS4 index doesn't rank the matches itself, it only returns the statistics to rank them with
+	q := fmt.Sprintf(`select %s, score from contributions
+		join (select sourceurl as matched, matchinfo(contributions_search, 'pcx') as score from contributions_search where contributions_search match ?) on sourceurl = matched
+		where not hidden`, contributionColumns)
+	args := []interface{}{query}
+	if len(opts.Type) > 0 {
+		q += " and upper(replace(contributiontype, 'flogo:', '')) = upper(replace(?, 'flogo:', ''))"
+		args = append(args, opts.Type)
+	}
+	if len(opts.Author) > 0 {
+		q += " and author = ? collate nocase"
+		args = append(args, opts.Author)
+	}
+
+	blocklist, err := db.Blocklist()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error while searching for %s: %s", terms, err.Error())
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		var info []byte
+		if r.Contribution, err = scanContribution(rows, &info); err != nil {
+			return nil, fmt.Errorf("error while searching for %s: %s", terms, err.Error())
+		}
+		if blocklist.BlockedContribution(r.Contribution) {
+			continue
+		}
+		r.Score = matchinfoScore(info)
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error while searching for %s: %s", terms, err.Error())
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].SourceURL < results[j].SourceURL
+	})
+
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// matchinfoScore ranks a match of the FTS4 index using the 'pcx' statistics returned by matchinfo. For every word and
+// column the number of times the word is found in the column of this contribution is divided by the number of times
+// it's found in that column of all contributions, so rare words count more, and multiplied by the weight of the
+// column.
+func matchinfoScore(info []byte) float64 {
+	values := make([]uint32, len(info)/4)
+	for idx := range values {
+		values[idx] = binary.LittleEndian.Uint32(info[idx*4:])
+	}
+	if len(values) < 2 {
+		return 0
+	}
+
+	phrases, columns := int(values[0]), int(values[1])
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns && c < len(searchWeights); c++ {
+			offset := 2 + 3*(p*columns+c)
+			if offset+1 >= len(values) {
+				return score
+			}
+			hits, total := values[offset], values[offset+1]
+			if hits > 0 {
+				score += searchWeights[c] * float64(hits) / float64(total)
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,96 @@
+// Package database manages storage
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Status of a contribution after its descriptor has been verified
+const (
+	// StatusPresent means the descriptor still exists on GitHub
+	StatusPresent = "present"
+	// StatusMissing means the descriptor, or the repository it was in, no longer exists
+	StatusMissing = "missing"
+)
+
+// MarkPresent records that the descriptor of the contribution with the source url still existed at the time. A
+// contribution that was missing or hidden before is shown again, which is recorded in the history.
+func (db *Database) MarkPresent(sourceURL string, t time.Time) error {
+	return db.changeContribution(sourceURL, t, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("update contributions set status=?, checkedon=?, missingsince=null, hidden=? where sourceurl=?",
+			StatusPresent, t.UTC().Format(time.RFC3339), false, sourceURL)
+		if err != nil {
+			return fmt.Errorf("error marking %s as present: %s", sourceURL, err.Error())
+		}
+		return nil
+	})
+}
+
+// MarkMissing records that the descriptor of the contribution with the source url no longer existed at the time. The
+// time the contribution went missing is only set the first time, so it tells how long the contribution has been gone.
+func (db *Database) MarkMissing(sourceURL string, t time.Time) error {
+	ts := t.UTC().Format(time.RFC3339)
+	_, err := db.DB.Exec("update contributions set status=?, checkedon=?, missingsince=ifnull(missingsince, ?) where sourceurl=?",
+		StatusMissing, ts, ts, sourceURL)
+	if err != nil {
+		return fmt.Errorf("error marking %s as missing: %s", sourceURL, err.Error())
+	}
+	return nil
+}
+
+// Prune removes the contributions that have been missing since before the cutoff, together with their descriptors
+// and findings. When hide is true the contributions are hidden instead, so they are kept in the database but no
+// longer exported. The number of pruned contributions is returned and the changes are recorded in the history.
+func (db *Database) Prune(cutoff time.Time, hide bool) (int, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %s", err.Error())
+	}
+	defer tx.Rollback()
+
+	// Contributions that are already hidden are only selected to be removed
+	var sourceURLs []string
+	err = tx.Select(&sourceURLs, "select sourceurl from contributions where status=? and missingsince<=? and (? or not hidden)",
+		StatusMissing, cutoff.UTC().Format(time.RFC3339), !hide)
+	if err != nil {
+		return 0, fmt.Errorf("error looking up missing contributions: %s", err.Error())
+	}
+
+	now := time.Now()
+	for _, sourceURL := range sourceURLs {
+		old, err := contribution(tx, sourceURL)
+		if err != nil {
+			return 0, fmt.Errorf("error looking up %s: %s", sourceURL, err.Error())
+		}
+
+		if hide {
+			if _, err = tx.Exec("update contributions set hidden=? where sourceurl=?", true, sourceURL); err != nil {
+				return 0, fmt.Errorf("error hiding %s: %s", sourceURL, err.Error())
+			}
+			new := *old
+			new.Hidden = true
+			if err = db.recordChange(tx, HistoryUpdate, old, &new, now); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		for _, table := range []string{"contributions", "descriptors", "attributes", "findings"} {
+			if _, err = tx.Exec(fmt.Sprintf("delete from %s where sourceurl=?", table), sourceURL); err != nil {
+				return 0, fmt.Errorf("error removing %s from %s: %s", sourceURL, table, err.Error())
+			}
+		}
+		if err = db.recordChange(tx, HistoryDelete, old, nil, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %s", err.Error())
+	}
+
+	return len(sourceURLs), nil
+}
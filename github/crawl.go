@@ -1,28 +1,54 @@
 package github
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/retgits/fdio/database"
 )
 
 const (
-	apiEndpoint       = "https://api.github.com"
 	searchPath        = "search/code"
-	activityQuery     = "sort=indexed&order=desc&q=filename%3Aactivity.json+flogo"
-	triggerQuery      = "sort=indexed&order=desc&q=filename%3Atrigger.json+flogo"
-	contributionQuery = "sort=indexed&order=desc&q=filename%3Adescriptor.json+flogo"
+	activityQuery     = "filename:activity.json flogo"
+	triggerQuery      = "filename:trigger.json flogo"
+	contributionQuery = "filename:descriptor.json flogo"
+	functionQuery     = `filename:descriptor.json "flogo:function"`
+	actionQuery       = `filename:descriptor.json "flogo:action"`
+	connectionQuery   = `filename:descriptor.json "flogo:connection"`
+
+	// searchPerPage is the number of results requested per page, which is the maximum GitHub allows
+	searchPerPage = 100
+
+	// SearchResultCap is the maximum number of results GitHub returns for a single code search. When more files match
+	// the query, the total count reports all of them but the pages stop at the cap.
+	SearchResultCap = 1000
+
+	// defaultBranch is used in the source url of a contribution when the default branch of its repository is unknown
+	defaultBranch = "master"
+
+	// DefaultConcurrency is the number of files and repositories fetched at the same time when the crawl options
+	// don't specify it
+	DefaultConcurrency = 4
 )
 
+// ContributionIdentifier is the kind of contribution a crawl searches for. ContributionType searches for all
+// descriptor.json files, the contributions it finds are stored with the kind set in their descriptor.
 type ContributionIdentifier int
 
 const (
 	ActivityType ContributionIdentifier = iota
 	ContributionType
 	TriggerType
+	FunctionType
+	ActionType
+	ConnectionType
 )
 
 func (c ContributionIdentifier) String() string {
@@ -30,11 +56,174 @@ func (c ContributionIdentifier) String() string {
 		"ACTIVITY",
 		"CONTRIBUTION",
 		"TRIGGER",
+		"FUNCTION",
+		"ACTION",
+		"CONNECTION",
 	}[c]
 }
 
-// Crawl will search on GitHub for files that are related to Flogo
-func Crawl(token string, db *database.Database, timeout float64, ci ContributionIdentifier) error {
+// ContributionIdentifiers lists all kinds of contributions that can be crawled
+var ContributionIdentifiers = []ContributionIdentifier{ActivityType, ContributionType, TriggerType, FunctionType, ActionType, ConnectionType}
+
+// CrawlTypes returns the kinds of contributions to crawl in a run that crawls all the kinds, leaving out the function,
+// action and connection searches when the run also searches for all descriptors (ContributionType), because those
+// searches only find descriptors that ContributionType already finds.
+func CrawlTypes(cis []ContributionIdentifier) []ContributionIdentifier {
+	all := false
+	for _, ci := range cis {
+		if ci == ContributionType {
+			all = true
+		}
+	}
+
+	var types []ContributionIdentifier
+	for _, ci := range cis {
+		if all && (ci == FunctionType || ci == ActionType || ci == ConnectionType) {
+			continue
+		}
+		types = append(types, ci)
+	}
+	return types
+}
+
+// ParseContributionIdentifier returns the kind of contribution with the name, ignoring case (so both "activity" and
+// "ACTIVITY" return ActivityType).
+func ParseContributionIdentifier(name string) (ContributionIdentifier, error) {
+	for _, ci := range ContributionIdentifiers {
+		if strings.EqualFold(ci.String(), name) {
+			return ci, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown type: %s", name)
+}
+
+// descriptorKind returns the kind of contribution set in the type field of a descriptor (like "flogo:activity"). It
+// returns false when the descriptor has no type or one that fdio doesn't know.
+func descriptorKind(descriptorType string) (ContributionIdentifier, bool) {
+	switch strings.ToLower(strings.TrimSpace(descriptorType)) {
+	case "flogo:activity":
+		return ActivityType, true
+	case "flogo:trigger":
+		return TriggerType, true
+	case "flogo:function":
+		return FunctionType, true
+	case "flogo:action":
+		return ActionType, true
+	case "flogo:connection":
+		return ConnectionType, true
+	}
+	return 0, false
+}
+
+// CrawlResult summarizes what a crawl has found
+type CrawlResult struct {
+	// Type is the type of contribution that was searched for
+	Type ContributionIdentifier
+
+	// TotalCount is the number of files on GitHub that match the search
+	TotalCount int64
+
+	// Pages is the number of pages of search results GitHub returns and PagesVisited the number of pages the crawl
+	// processed before it stopped
+	Pages        int
+	PagesVisited int
+
+	// Shards is the number of searches the crawl was split into to get past the result cap of GitHub
+	Shards int
+
+	// Truncated is true when more files match a search than GitHub returns and the search could not be split any
+	// further, so not all files could be visited
+	Truncated bool
+
+	// Inserted, Updated and Unchanged count the contributions stored in the database, Skipped counts the files in
+	// repositories that weren't pushed to since the last crawl, Duplicates counts the files that an earlier crawl in
+	// the same run already visited, Forks counts the files in forks that are the same as in the upstream repository,
+	// Blocked counts the files the blocklist denies and Failed counts the files that could not be stored
+	Inserted   int
+	Updated    int
+	Unchanged  int
+	Skipped    int
+	Duplicates int
+	Forks      int
+	Blocked    int
+	Failed     int
+}
+
+// Add adds the counts of another crawl to the result, which is used to summarize crawls for several types. The type
+// of the result is not changed.
+func (r *CrawlResult) Add(other CrawlResult) {
+	r.TotalCount += other.TotalCount
+	r.Pages += other.Pages
+	r.PagesVisited += other.PagesVisited
+	r.Shards += other.Shards
+	r.Truncated = r.Truncated || other.Truncated
+	r.Inserted += other.Inserted
+	r.Updated += other.Updated
+	r.Unchanged += other.Unchanged
+	r.Skipped += other.Skipped
+	r.Duplicates += other.Duplicates
+	r.Forks += other.Forks
+	r.Blocked += other.Blocked
+	r.Failed += other.Failed
+}
+
+// Visited keeps track of the files that crawls have visited, so crawls for different types in the same run (which
+// can find the same descriptor.json) visit each file only once. A Visited is safe to share between crawls.
+type Visited struct {
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+// NewVisited returns an empty set of visited files.
+func NewVisited() *Visited {
+	return &Visited{files: make(map[string]bool)}
+}
+
+// visit marks the file as visited and returns false if it was already visited before.
+func (v *Visited) visit(item Item) bool {
+	key := fmt.Sprintf("%s/%s", item.Repository.FullName, item.Path)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.files[key] {
+		return false
+	}
+	v.files[key] = true
+	return true
+}
+
+// CrawlOptions configures a crawl
+type CrawlOptions struct {
+	// Timeout is the number of hours between now and the last update of a repository after which the crawl stops,
+	// 0 (or a negative number) crawls all results
+	Timeout float64
+
+	// Concurrency is the number of descriptors and repository details fetched at the same time, defaults to
+	// DefaultConcurrency
+	Concurrency int
+
+	// Permalinks stores, next to the source url that points to the default branch of the repository, a link to the
+	// descriptor at the commit it was found in. That link keeps pointing to the same content when the branch changes.
+	Permalinks bool
+
+	// Since skips search results in repositories that haven't been pushed to since this time, which makes a crawl
+	// that only needs to pick up new and changed contributions much faster. The zero time processes all results.
+	Since time.Time
+
+	// Visited skips the files that other crawls sharing the same Visited already processed. When it is nil, all
+	// files are processed.
+	Visited *Visited
+
+	// IncludeForks stores descriptors found in forks as contributions even when the upstream repository has the same
+	// descriptor. The relation between a fork and its upstream repository is recorded either way.
+	IncludeForks bool
+}
+
+// Crawl will search on GitHub for files that are related to Flogo. The descriptors and repository details on a page
+// of search results are fetched concurrently, but the contributions are stored in the database one at a time and in
+// the order of the search results.
+func (c *Client) Crawl(db *database.Database, ci ContributionIdentifier, opts CrawlOptions) (CrawlResult, error) {
 	var searchQuery string
 	var legacy bool
 	var pathString string
@@ -52,104 +241,519 @@ func Crawl(token string, db *database.Database, timeout float64, ci Contribution
 		searchQuery = contributionQuery
 		legacy = false
 		pathString = "descriptor.json"
+	case FunctionType:
+		searchQuery = functionQuery
+		legacy = false
+		pathString = "descriptor.json"
+	case ActionType:
+		searchQuery = actionQuery
+		legacy = false
+		pathString = "descriptor.json"
+	case ConnectionType:
+		searchQuery = connectionQuery
+		legacy = false
+		pathString = "descriptor.json"
+	default:
+		return CrawlResult{Type: ci}, fmt.Errorf("unknown type: %d", ci)
+	}
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	blocklist, err := db.Blocklist()
+	if err != nil {
+		return CrawlResult{Type: ci}, err
+	}
+
+	cr := &crawler{
+		client:     c,
+		db:         db,
+		ci:         ci,
+		opts:       opts,
+		legacy:     legacy,
+		pathString: pathString,
+		result:     CrawlResult{Type: ci},
+		blocklist:  blocklist,
+		refreshed:  make(map[string]bool),
+	}
+
+	err = cr.crawl(shard{query: searchQuery})
+	return cr.result, err
+}
+
+// crawler holds the settings and the progress of a single crawl
+type crawler struct {
+	client     *Client
+	db         *database.Database
+	ci         ContributionIdentifier
+	opts       CrawlOptions
+	legacy     bool
+	pathString string
+	result     CrawlResult
+
+	// blocklist decides which contributions are not stored
+	blocklist database.Blocklist
+
+	// refreshed holds the full names of the repositories whose details were stored during the crawl
+	refreshed map[string]bool
+
+	// stopped is set when the timeout is reached, after which no more shards or pages are visited
+	stopped bool
+}
+
+// crawl visits all pages of search results of the shard. When more files match the shard than GitHub returns, the
+// shard is split in two and both halves are crawled instead.
+func (cr *crawler) crawl(s shard) error {
+	res, err := cr.client.getSearchResults(cr.client.searchURL(s.String()))
+	if err != nil {
+		return err
+	}
+
+	if !s.sharded() {
+		cr.result.TotalCount = res.TotalCount
+	}
+
+	if res.TotalCount > int64(cr.client.resultCap) {
+		if lower, upper, ok := s.split(); ok {
+			log.Printf("There are %d files matching %q, splitting the search by file size", res.TotalCount, s.String())
+			if err := cr.crawl(lower); err != nil {
+				return err
+			}
+			if cr.stopped {
+				return nil
+			}
+			return cr.crawl(upper)
+		}
+		cr.result.Truncated = true
+		log.Printf("GitHub only returns the first %d results, %d files matching %q will not be visited", cr.client.resultCap, res.TotalCount-int64(cr.client.resultCap), s.String())
 	}
 
-	var maxPages int
-	i := 1
+	pages := res.LastPage
+	if pages == 0 && len(res.Items) > 0 {
+		pages = 1
+	}
+	cr.result.Shards++
+	cr.result.Pages += pages
+	log.Printf("There are a total of %d files on %d pages for %q", res.TotalCount, pages, s.String())
 
 	for {
-		// Prepare URL
-		URL := fmt.Sprintf("%s/%s?%s&page=%v", apiEndpoint, searchPath, searchQuery, i)
+		cr.result.PagesVisited++
+		if !cr.storePage(res) {
+			return nil
+		}
 
-		res, err := getSearchResults(URL, token)
+		// Continue with the next page, the last page has no link to a next one
+		if len(res.NextURL) == 0 {
+			return nil
+		}
+		res, err = cr.client.getSearchResults(res.NextURL)
 		if err != nil {
 			return err
 		}
+	}
+}
 
-		// Check how many pages exist
-		// Only do this the first time
-		if i == 0 {
-			maxPages = getMaxPages(res.HTTPHeaders)
-		}
+// fetched holds the data fetched from GitHub for a single search result
+type fetched struct {
+	item       Item
+	descriptor FlogoDescriptor
+	err        error
+	repo       RepoDetails
+	repoErr    error
 
-		// Add the items to the database
-		for _, repo := range res.Items {
-			activityURL := strings.Replace(repo.HTMLURL, "github.com", "raw.githubusercontent.com", 1)
-			activityURL = strings.ReplaceAll(activityURL, "blob/", "")
+	// upstream is the descriptor at the same location in the repository a fork was created from, it is only fetched
+	// for forks
+	upstream    FlogoDescriptor
+	upstreamErr error
+}
+
+// fetch gets the descriptors and repository details of the search results using a pool of workers. The results are
+// returned in the same order as the items.
+func (cr *crawler) fetch(items []Item) []fetched {
+	results := make([]fetched, len(items))
+	jobs := make(chan int)
 
-			activity, err := getActivityContent(activityURL)
-			if err != nil {
-				log.Printf("unable to get data for %s: %s", repo.HTMLURL, err.Error())
+	var wg sync.WaitGroup
+	for w := 0; w < cr.opts.Concurrency && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				item := items[idx]
+				results[idx].item = item
+				results[idx].repo, results[idx].repoErr = cr.client.repository(item.Repository.FullName)
+				if results[idx].repoErr != nil || cr.skip(results[idx]) {
+					// The result is skipped, so there is no need to download its descriptors
+					continue
+				}
+				results[idx].descriptor, results[idx].err = cr.client.getDescriptor(cr.client.rawContentURL(item))
+				if upstream := upstreamOf(results[idx].repo); results[idx].err == nil && upstream != nil {
+					results[idx].upstream, results[idx].upstreamErr = cr.client.getDescriptor(cr.client.rawFileURL(upstream.FullName, branchOf(*upstream), item.Path))
+				}
+			}
+		}()
+	}
+
+	for idx := range items {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// skip returns true when the repository of the search result wasn't pushed to since the time set in the options.
+func (cr *crawler) skip(f fetched) bool {
+	return f.repoErr == nil && !cr.opts.Since.IsZero() && !pushedSince(f.repo, cr.opts.Since)
+}
+
+// storePage adds the contributions on a page of search results to the database. It returns false when the crawl of
+// the current search should stop.
+func (cr *crawler) storePage(res GithubData) bool {
+	items := res.Items
+	if cr.opts.Visited != nil {
+		items = nil
+		for _, item := range res.Items {
+			if !cr.opts.Visited.visit(item) {
+				cr.result.Duplicates++
 				continue
 			}
+			items = append(items, item)
+		}
+	}
+
+	// Add the items to the database
+	for _, f := range cr.fetch(items) {
+		repo, activity := f.item, f.descriptor
+		// Without the details of the repository the source url can't point to its default branch
+		if f.repoErr != nil {
+			log.Printf("unable to get details of %s: %s", repo.Repository.FullName, f.repoErr.Error())
+			cr.result.Failed++
+			continue
+		}
+
+		if cr.skip(f) {
+			cr.result.Skipped++
+			continue
+		}
+
+		branch := branchOf(f.repo)
+
+		path := strings.Replace(repo.Path, cr.pathString, "", 1)
+		sourceURL := fmt.Sprintf("%s/tree/%s/%s", repo.Repository.HTMLURL, branch, path)
+
+		if f.err != nil {
+			log.Printf("unable to get data for %s: %s", repo.HTMLURL, f.err.Error())
+			cr.result.Failed++
 
-			path := strings.Replace(repo.Path, pathString, "", 1)
-
-			contribution := database.Contribution{
-				Author:           repo.Repository.Owner.Login,
-				ContributionType: ci.String(),
-				Description:      activity.Description,
-				Homepage:         activity.Homepage,
-				Legacy:           legacy,
-				Name:             activity.Name,
-				Ref:              activity.Ref,
-				ShowcaseEnabled:  false,
-				SourceURL:        fmt.Sprintf("https://github.com/%s/tree/master/%s", repo.Repository.FullName, path),
-				Title:            activity.Title,
-				UploadedOn:       time.Now().Format("2006-01-02"),
-				Version:          activity.Version,
+			// A file that isn't valid JSON is recorded, so it shows up when the descriptors are linted
+			var invalid *InvalidDescriptorError
+			if errors.As(f.err, &invalid) {
+				cr.saveFindings(sourceURL, []Finding{{Rule: JSONRule, Message: fmt.Sprintf("%s is not a valid descriptor: %s", repo.Path, invalid.Err.Error())}})
 			}
+			continue
+		}
 
-			err = db.InsertContribution(contribution)
-			if err != nil {
-				if strings.Contains(err.Error(), "UNIQUE constraint failed: contributions.sourceurl") {
-					err = db.UpdateContribution(contribution)
-					if err != nil {
-						log.Printf("unable to update data for %s (%s): %s", activity.Title, repo.Repository.FullName, err.Error())
-						continue
-					}
-				} else {
-					log.Printf("unable to add %s (%s) to database: %s", activity.Title, repo.Repository.FullName, err.Error())
-					continue
-				}
+		if cr.blocklist.Blocked(repo.Repository.FullName, activity.Ref) {
+			log.Printf("skipping %s (%s), it is on the blocklist", activity.Title, repo.Repository.FullName)
+			cr.result.Blocked++
+			continue
+		}
+
+		if upstream := upstreamOf(f.repo); upstream != nil {
+			if collapsed := cr.recordFork(f, *upstream, sourceURL, path); collapsed && !cr.opts.IncludeForks {
+				log.Printf("skipping %s (%s), the descriptor is the same as in %s", activity.Title, repo.Repository.FullName, upstream.FullName)
+				cr.result.Forks++
+				continue
 			}
+		}
 
-			log.Printf("added %s (%s) to database", activity.Title, repo.Repository.FullName)
+		var permalink string
+		if ref := commitRef(repo); cr.opts.Permalinks && len(ref) > 0 {
+			permalink = fmt.Sprintf("%s/tree/%s/%s", repo.Repository.HTMLURL, ref, path)
 		}
 
-		// Check the last update time
-		if len(res.Items) == 0 {
-			return nil
+		// Store the kind set in the descriptor, descriptors without a (known) type get the kind that was searched for
+		kind, ok := descriptorKind(activity.Type)
+		if !ok {
+			kind = cr.ci
 		}
 
-		lastActivity := res.Items[len(res.Items)-1]
-		duration, err := repoLastUpdated(lastActivity.Repository.FullName)
+		// The time is used as the time the contribution was first discovered when it's new, and as the time it last
+		// changed when any of its fields differ from what is stored
+		now := time.Now().UTC().Truncate(time.Second)
+		contribution := database.Contribution{
+			Author:           repo.Repository.Owner.Login,
+			ContributionType: kind.String(),
+			Description:      activity.Description,
+			Homepage:         activity.Homepage,
+			Legacy:           cr.legacy,
+			Name:             activity.Name,
+			Ref:              activity.Ref,
+			ShowcaseEnabled:  false,
+			SourceURL:        sourceURL,
+			Permalink:        permalink,
+			Title:            activity.Title,
+			UploadedOn:       now,
+			LastCrawled:      now,
+			LastChanged:      now,
+			Version:          activity.Version,
+			Repository:       repo.Repository.FullName,
+		}
+
+		cr.saveRepository(f.repo)
+
+		res, err := cr.db.UpsertContribution(contribution)
 		if err != nil {
-			log.Printf("unable to determine last update of %s to database: %s", lastActivity.Repository.FullName, err.Error())
+			log.Printf("unable to store %s (%s) in database: %s", activity.Title, repo.Repository.FullName, err.Error())
+			cr.result.Failed++
+			continue
 		}
+		cr.result.count(res)
 
-		// If update is larger than timeout it means the last update to the last checked
-		// repository was longer than the timeout we set. In that case we don't need to
-		// scan any further
-		if duration > timeout && timeout != -1 {
-			log.Printf("Maximum timeout reached. Last repo update was %v hours\n", duration)
-			return nil
+		if err = cr.db.SaveDescriptor(descriptorRecord(contribution.SourceURL, activity)); err != nil {
+			log.Printf("unable to store the descriptor of %s (%s) in database: %s", activity.Title, repo.Repository.FullName, err.Error())
 		}
+		cr.saveFindings(contribution.SourceURL, ValidateDescriptor(activity, repo.Repository.FullName, repo.Path))
 
-		// Stop if the maxPages is reached
-		if i++; i == maxPages {
-			return nil
+		log.Printf("%s %s (%s) in database", res.String(), activity.Title, repo.Repository.FullName)
+	}
+
+	// Check the last update time
+	if len(res.Items) == 0 {
+		return false
+	}
+
+	lastActivity := res.Items[len(res.Items)-1]
+	duration, err := cr.client.repoLastUpdated(lastActivity.Repository.FullName)
+	if err != nil {
+		log.Printf("unable to determine last update of %s to database: %s", lastActivity.Repository.FullName, err.Error())
+	}
+
+	// If update is larger than timeout it means the last update to the last checked
+	// repository was longer than the timeout we set. In that case we don't need to
+	// scan any further
+	if cr.opts.Timeout > 0 && duration > cr.opts.Timeout {
+		log.Printf("Maximum timeout reached. Last repo update was %v hours\n", duration)
+		cr.stopped = true
+		return false
+	}
+
+	return true
+}
+
+// recordFork stores the relation between the descriptor found in a fork and the descriptor at the same location in the
+// upstream repository. It returns true if both descriptors have the same ref and version, so the fork doesn't have to
+// be stored as a separate contribution.
+func (cr *crawler) recordFork(f fetched, upstream RepoDetails, sourceURL string, dir string) bool {
+	fork := database.Fork{
+		SourceURL: sourceURL,
+		Upstream:  fmt.Sprintf("%s/tree/%s/%s", upstream.HTMLURL, branchOf(upstream), dir),
+		Ref:       f.descriptor.Ref,
+		Version:   f.descriptor.Version,
+	}
+
+	var invalid *InvalidDescriptorError
+	var statusErr *StatusError
+	switch {
+	case f.upstreamErr == nil && f.upstream.Ref == f.descriptor.Ref && f.upstream.Version == f.descriptor.Version:
+		fork.Status = database.ForkCollapsed
+	case f.upstreamErr == nil || errors.As(f.upstreamErr, &invalid):
+		fork.Status = database.ForkDiverged
+	case errors.As(f.upstreamErr, &statusErr) && statusErr.StatusCode == http.StatusNotFound:
+		fork.Status = database.ForkUnique
+	default:
+		// Without the upstream descriptor it's unknown whether the fork is different, so it is kept
+		log.Printf("unable to get the upstream descriptor of %s from %s: %s", sourceURL, upstream.FullName, f.upstreamErr.Error())
+		return false
+	}
+
+	if err := cr.db.SaveFork(fork, time.Now()); err != nil {
+		log.Printf("unable to store fork %s in database: %s", sourceURL, err.Error())
+	}
+
+	return fork.Status == database.ForkCollapsed
+}
+
+// upstreamOf returns the repository at the root of the network of forks the repository belongs to, or nil if the
+// repository is not a fork.
+func upstreamOf(repo RepoDetails) *RepoDetails {
+	if !repo.Fork {
+		return nil
+	}
+	if repo.Source != nil {
+		return repo.Source
+	}
+	return repo.Parent
+}
+
+// branchOf returns the default branch of the repository, or defaultBranch if it's unknown.
+func branchOf(repo RepoDetails) string {
+	if len(repo.DefaultBranch) == 0 {
+		return defaultBranch
+	}
+	return repo.DefaultBranch
+}
+
+// saveRepository stores the details of the repository, once per crawl.
+func (cr *crawler) saveRepository(repo RepoDetails) {
+	if cr.refreshed[repo.FullName] {
+		return
+	}
+	cr.refreshed[repo.FullName] = true
+
+	if err := cr.db.SaveRepository(repositoryRecord(repo), time.Now()); err != nil {
+		log.Printf("unable to store the details of %s in database: %s", repo.FullName, err.Error())
+	}
+}
+
+// repositoryRecord converts the details of a repository into the record that is stored in the database.
+func repositoryRecord(repo RepoDetails) database.Repository {
+	license := database.NoLicense
+	if repo.License != nil && len(repo.License.SPDXID) > 0 && repo.License.SPDXID != "NOASSERTION" {
+		license = repo.License.SPDXID
+	} else if repo.License != nil && len(repo.License.Key) > 0 {
+		license = repo.License.Key
+	}
+
+	return database.Repository{
+		FullName:      repo.FullName,
+		HTMLURL:       repo.HTMLURL,
+		Description:   repo.Description,
+		Stars:         repo.StargazersCount,
+		Forks:         repo.ForksCount,
+		License:       license,
+		Topics:        repo.Topics,
+		Archived:      repo.Archived,
+		DefaultBranch: repo.DefaultBranch,
+		PushedAt:      githubTime(repo.PushedAt),
+		UpdatedAt:     githubTime(repo.UpdatedAt),
+	}
+}
+
+// githubTime parses a time returned by the GitHub API, it returns the zero time when the time isn't set or valid.
+func githubTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// saveFindings stores the findings of the validator for the contribution with the source url, replacing the findings
+// of earlier crawls.
+func (cr *crawler) saveFindings(sourceURL string, findings []Finding) {
+	records := make([]database.Finding, len(findings))
+	for idx, f := range findings {
+		records[idx] = database.Finding{SourceURL: sourceURL, Rule: f.Rule, Message: f.Message}
+	}
+
+	if err := cr.db.SaveFindings(sourceURL, records); err != nil {
+		log.Printf("unable to store the findings of %s in database: %s", sourceURL, err.Error())
+	}
+}
+
+// descriptorRecord converts the descriptor of the contribution with the source url into the record that is stored in
+// the database.
+func descriptorRecord(sourceURL string, d FlogoDescriptor) database.Descriptor {
+	record := database.Descriptor{
+		SourceURL: sourceURL,
+		Author:    d.Author,
+		Raw:       string(d.Raw),
+	}
+
+	if d.Display != nil {
+		record.Category = d.Display.Category
+		record.Visible = bool(d.Display.Visible)
+		record.SmallIcon = d.Display.SmallIcon
+		record.LargeIcon = d.Display.LargeIcon
+	}
+
+	add := func(section string, attributes []Attribute) {
+		for idx, a := range attributes {
+			record.Attributes = append(record.Attributes, database.Attribute{
+				Section:     section,
+				Position:    idx,
+				Name:        a.Name,
+				Type:        a.Type,
+				Required:    bool(a.Required),
+				Value:       string(a.Value),
+				Allowed:     string(a.Allowed),
+				Description: a.Description,
+			})
 		}
+	}
 
-		// Wait for 5 seconds so the GitHub search API limit won't be breached
-		time.Sleep(10 * time.Second)
+	add(database.SettingsSection, d.Settings)
+	add(database.InputSection, d.Input)
+	add(database.OutputSection, d.Output)
+	add(database.ReplySection, d.Reply)
+	if d.Handler != nil {
+		add(database.HandlerSettingsSection, d.Handler.Settings)
 	}
+
+	return record
+}
+
+// count adds the outcome of storing a contribution to the result.
+func (r *CrawlResult) count(res database.UpsertResult) {
+	switch res {
+	case database.Inserted:
+		r.Inserted++
+	case database.Updated:
+		r.Updated++
+	case database.Unchanged:
+		r.Unchanged++
+	}
+}
+
+// searchURL returns the URL of the first page of results for the code search query. Results are sorted so the most
+// recently indexed files come first.
+func (c *Client) searchURL(query string) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("sort", "indexed")
+	params.Set("order", "desc")
+	params.Set("per_page", strconv.Itoa(searchPerPage))
+	return fmt.Sprintf("%s?%s", c.apiURL(searchPath), params.Encode())
 }
 
-func repoLastUpdated(repo string) (float64, error) {
-	url := fmt.Sprintf("%s/repos/%s", apiEndpoint, repo)
+// pushedSince returns true if the repository was pushed to after the time. Repositories without a valid push time
+// are considered to be pushed to, so they're never skipped by mistake.
+func pushedSince(repo RepoDetails, t time.Time) bool {
+	pushedAt, err := time.Parse(time.RFC3339, repo.PushedAt)
+	if err != nil {
+		return true
+	}
+	return pushedAt.After(t)
+}
+
+// repository returns the details of the repository. The details are fetched once and cached for the lifetime of the
+// client, so crawling many files of the same repository doesn't use up the rate limit.
+func (c *Client) repository(fullName string) (RepoDetails, error) {
+	c.mu.Lock()
+	repo, ok := c.repos[fullName]
+	c.mu.Unlock()
+	if ok {
+		return repo, nil
+	}
+
+	repo, err := c.getRepoDetails(c.apiURL(fmt.Sprintf("repos/%s", fullName)))
+	if err != nil {
+		return repo, err
+	}
+
+	c.mu.Lock()
+	c.repos[fullName] = repo
+	c.mu.Unlock()
+
+	return repo, nil
+}
 
-	res, err := getRepoDetails(url)
+func (c *Client) repoLastUpdated(repo string) (float64, error) {
+	res, err := c.repository(repo)
 	if err != nil {
 		return 0, err
 	}
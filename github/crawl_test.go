@@ -0,0 +1,507 @@
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retgits/fdio/database"
+	"github.com/retgits/fdio/github/githubtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CrawlTestSuite struct {
+	suite.Suite
+	dir    string
+	db     *database.Database
+	server *githubtest.Server
+	client *Client
+	opts   CrawlOptions
+}
+
+func (suite *CrawlTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "fdio")
+	suite.Require().NoError(err)
+	suite.dir = dir
+
+	dbFile := filepath.Join(dir, "crawl.db")
+	os.Create(dbFile)
+	suite.db = database.MustOpenSession(dbFile)
+	suite.Require().NoError(suite.db.Initialize())
+
+	suite.server, err = githubtest.NewServer("./testdata/repos")
+	suite.Require().NoError(err)
+
+	suite.client = NewClient(ClientOptions{
+		Token:      "token",
+		BaseURL:    suite.server.URL,
+		RawURL:     suite.server.RawURL(),
+		HTTPClient: suite.server.Client(),
+	})
+	suite.opts = CrawlOptions{Timeout: -1}
+}
+
+func (suite *CrawlTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.db.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *CrawlTestSuite) TestCrawlActivities() {
+	_, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 2)
+
+	assert.WithinDuration(suite.T(), time.Now(), contributions[0].UploadedOn, time.Minute)
+	assert.Equal(suite.T(), database.Contribution{
+		Ref:              "github.com/retgits/flogo-components/activity/hello",
+		Name:             "hello",
+		ContributionType: "ACTIVITY",
+		SourceURL:        "https://github.com/retgits/flogo-components/tree/master/activity/hello/",
+		Author:           "retgits",
+		UploadedOn:       contributions[0].UploadedOn,
+		LastCrawled:      contributions[0].UploadedOn,
+		LastChanged:      contributions[0].UploadedOn,
+		Description:      `Say "hello" to the world`,
+		Version:          "0.0.1",
+		Title:            "Hello",
+		Homepage:         "https://github.com/retgits/flogo-components/tree/master/activity/hello",
+		Legacy:           true,
+		Repository:       "retgits/flogo-components",
+	}, contributions[0])
+	assert.Equal(suite.T(), "writetofile", contributions[1].Name)
+	assert.Equal(suite.T(), "https://github.com/retgits/flogo-components/tree/master/activity/writetofile/", contributions[1].SourceURL)
+}
+
+func (suite *CrawlTestSuite) TestCrawlTriggers() {
+	_, err := suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	// The copy of pubnubsubscriber in the fork is skipped, the trigger that only exists in the fork is stored
+	assert.Len(suite.T(), contributions, 2)
+	assert.Equal(suite.T(), "mqtt", contributions[0].Name)
+	assert.Equal(suite.T(), "pubnubsubscriber", contributions[1].Name)
+	assert.Equal(suite.T(), "TRIGGER", contributions[1].ContributionType)
+	assert.Equal(suite.T(), "https://github.com/retgits/flogo-components/tree/master/trigger/pubnubsubscriber/", contributions[1].SourceURL)
+	assert.True(suite.T(), contributions[1].Legacy)
+
+	d, err := suite.db.Descriptor(contributions[1].SourceURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "retgits", d.Author)
+	assert.Equal(suite.T(), []database.Attribute{
+		{Section: "handler", Position: 0, Name: "channel", Type: "string", Required: true},
+		{Section: "output", Position: 0, Name: "message", Type: "string"},
+		{Section: "settings", Position: 0, Name: "publishKey", Type: "string", Required: true},
+		{Section: "settings", Position: 1, Name: "subscribeKey", Type: "string", Required: true},
+	}, d.Attributes)
+}
+
+func (suite *CrawlTestSuite) TestCrawlFindings() {
+	result, err := suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Inserted)
+	assert.Equal(suite.T(), 1, result.Failed)
+
+	_, err = suite.client.Crawl(suite.db, ContributionType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	findings, err := suite.db.Findings()
+	assert.NoError(suite.T(), err)
+
+	bySource := make(map[string][]string)
+	for _, f := range findings {
+		bySource[f.SourceURL] = append(bySource[f.SourceURL], f.Rule)
+	}
+	// The descriptor.json files don't have a ref, which is fine because it comes from the import path
+	assert.Equal(suite.T(), map[string][]string{
+		"https://github.com/someone/broken-flogo/tree/master/trigger/mqtt/": {JSONRule},
+	}, bySource)
+}
+
+func (suite *CrawlTestSuite) TestCrawlForks() {
+	result, err := suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, result.Forks)
+
+	forks, err := suite.db.Forks()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), forks, 2)
+	assert.Equal(suite.T(), "https://github.com/forker/flogo-components/tree/master/trigger/mqtt/", forks[0].SourceURL)
+	assert.Equal(suite.T(), database.ForkUnique, forks[0].Status)
+	assert.Equal(suite.T(), "https://github.com/forker/flogo-components/tree/master/trigger/pubnubsubscriber/", forks[1].SourceURL)
+	assert.Equal(suite.T(), "https://github.com/retgits/flogo-components/tree/master/trigger/pubnubsubscriber/", forks[1].Upstream)
+	assert.Equal(suite.T(), database.ForkCollapsed, forks[1].Status)
+	assert.Equal(suite.T(), "0.0.1", forks[1].Version)
+
+	// A fork with a different version is stored as a separate contribution
+	suite.server.WriteFile("forker/flogo-components", "trigger/pubnubsubscriber/trigger.json", []byte(`{
+		"name": "pubnubsubscriber",
+		"type": "flogo:trigger",
+		"ref": "github.com/retgits/flogo-components/trigger/pubnubsubscriber",
+		"version": "0.0.2"
+	}`))
+
+	result, err = suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, result.Forks)
+	assert.Equal(suite.T(), 1, result.Inserted)
+
+	forks, err = suite.db.Forks()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.ForkDiverged, forks[1].Status)
+	assert.Equal(suite.T(), "0.0.2", forks[1].Version)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 3)
+}
+
+func (suite *CrawlTestSuite) TestCrawlIncludeForks() {
+	suite.opts.IncludeForks = true
+
+	result, err := suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, result.Forks)
+	assert.Equal(suite.T(), 3, result.Inserted)
+
+	forks, err := suite.db.Forks()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), forks, 2)
+}
+
+func (suite *CrawlTestSuite) TestCrawlBlocklist() {
+	now := time.Now()
+	suite.Require().NoError(suite.db.AddRule(database.Rule{Kind: database.OwnerRule, Pattern: "forker", Action: database.Deny}, now))
+	suite.Require().NoError(suite.db.AddRule(database.Rule{Kind: database.RefRule, Pattern: "github.com/retgits/*/trigger/*", Action: database.Deny}, now))
+	suite.Require().NoError(suite.db.AddRule(database.Rule{Kind: database.RefRule, Pattern: "github.com/retgits/flogo-components/trigger/pubnub*", Action: database.Allow}, now))
+
+	result, err := suite.client.Crawl(suite.db, TriggerType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	// The allow rule for pubnubsubscriber takes precedence over both deny rules, so only mqtt in the fork is denied.
+	// The copy of pubnubsubscriber in the fork is skipped because it is the same as upstream.
+	assert.Equal(suite.T(), 1, result.Blocked)
+	assert.Equal(suite.T(), 1, result.Forks)
+	assert.Equal(suite.T(), 1, result.Inserted)
+
+	forks, err := suite.db.Forks()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), forks, 1)
+}
+
+func (suite *CrawlTestSuite) TestCrawlContributions() {
+	_, err := suite.client.Crawl(suite.db, ContributionType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 5)
+
+	kinds := make(map[string]string)
+	for _, c := range contributions {
+		kinds[c.Name] = c.ContributionType
+		assert.False(suite.T(), c.Legacy)
+	}
+	assert.Equal(suite.T(), map[string]string{
+		"flogo-log":        "ACTIVITY",
+		"flogo-rest":       "TRIGGER",
+		"string":           "FUNCTION",
+		"flow":             "ACTION",
+		"kafka-connection": "CONNECTION",
+	}, kinds)
+
+	for _, c := range contributions {
+		switch c.Name {
+		case "flogo-log":
+			assert.Equal(suite.T(), "project-flogo", c.Author)
+			assert.Equal(suite.T(), "https://github.com/project-flogo/contrib/tree/main/activity/log/", c.SourceURL)
+		case "flogo-rest":
+			assert.Equal(suite.T(), "https://github.com/project-flogo/contrib/tree/main/trigger/rest/", c.SourceURL)
+		case "flow":
+			assert.Equal(suite.T(), "https://github.com/project-flogo/flow/tree/master/", c.SourceURL)
+		}
+	}
+}
+
+func (suite *CrawlTestSuite) TestCrawlKinds() {
+	for ci, name := range map[ContributionIdentifier]string{
+		FunctionType:   "string",
+		ActionType:     "flow",
+		ConnectionType: "kafka-connection",
+	} {
+		result, err := suite.client.Crawl(suite.db, ci, suite.opts)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), int64(1), result.TotalCount)
+		assert.Equal(suite.T(), 1, result.Inserted)
+
+		contributions, err := suite.db.Contributions()
+		assert.NoError(suite.T(), err)
+
+		var found bool
+		for _, c := range contributions {
+			if c.Name == name {
+				found = true
+				assert.Equal(suite.T(), ci.String(), c.ContributionType)
+			}
+		}
+		assert.True(suite.T(), found, "%s was not stored", name)
+	}
+}
+
+func (suite *CrawlTestSuite) TestCrawlAllTypes() {
+	suite.opts.Visited = NewVisited()
+	suite.opts.Concurrency = 1
+
+	// The function, action and connection are found by the search for all descriptors, so they aren't searched for
+	types := CrawlTypes(ContributionIdentifiers)
+	assert.Equal(suite.T(), []ContributionIdentifier{ActivityType, ContributionType, TriggerType}, types)
+
+	var total CrawlResult
+	for _, ci := range types {
+		result, err := suite.client.Crawl(suite.db, ci, suite.opts)
+		assert.NoError(suite.T(), err)
+		total.Add(result)
+	}
+
+	assert.Equal(suite.T(), 9, total.Inserted)
+	assert.Equal(suite.T(), 0, total.Duplicates)
+	assert.Equal(suite.T(), 1, total.Forks)
+	assert.Equal(suite.T(), 1, total.Failed)
+	assert.Equal(suite.T(), int64(11), total.TotalCount)
+	assert.Equal(suite.T(), 13, suite.server.Hits("raw"))
+	assert.Equal(suite.T(), 6, suite.server.Hits("repos"))
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 9)
+}
+
+func (suite *CrawlTestSuite) TestParseContributionIdentifier() {
+	for _, ci := range ContributionIdentifiers {
+		parsed, err := ParseContributionIdentifier(strings.ToLower(ci.String()))
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), ci, parsed)
+	}
+
+	_, err := ParseContributionIdentifier("flow")
+	assert.EqualError(suite.T(), err, "unknown type: flow")
+}
+
+func (suite *CrawlTestSuite) TestCrawlRepositories() {
+	_, err := suite.client.Crawl(suite.db, ContributionType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	repositories, err := suite.db.Repositories()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), repositories, 3)
+
+	contrib := repositories[0]
+	assert.Equal(suite.T(), "project-flogo/contrib", contrib.FullName)
+	assert.Equal(suite.T(), int64(42), contrib.Stars)
+	assert.Equal(suite.T(), int64(7), contrib.Forks)
+	assert.Equal(suite.T(), "BSD-3-Clause", contrib.License)
+	assert.Equal(suite.T(), []string{"flogo", "contributions"}, contrib.Topics)
+	assert.False(suite.T(), contrib.Archived)
+	assert.Equal(suite.T(), "main", contrib.DefaultBranch)
+	assert.Equal(suite.T(), time.Date(2020, 4, 28, 0, 0, 0, 0, time.UTC), contrib.PushedAt)
+	assert.False(suite.T(), contrib.RefreshedOn.IsZero())
+
+	flow := repositories[1]
+	assert.Equal(suite.T(), "project-flogo/flow", flow.FullName)
+	assert.Equal(suite.T(), database.NoLicense, flow.License)
+	assert.True(suite.T(), flow.Archived)
+
+	var buf bytes.Buffer
+	assert.NoError(suite.T(), suite.db.ExportItems(&buf, database.ExportOptions{SortBy: database.SortByStars}))
+
+	itemsFile, err := database.ParseItems(&buf)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), itemsFile.Items, 5)
+	assert.Equal(suite.T(), "flogo-log", itemsFile.Items[0].Name)
+	assert.Equal(suite.T(), int64(42), itemsFile.Items[0].Stars)
+	assert.Equal(suite.T(), "BSD-3-Clause", itemsFile.Items[0].License)
+	assert.Equal(suite.T(), "flow", itemsFile.Items[3].Name)
+	assert.True(suite.T(), itemsFile.Items[3].Archived)
+	assert.Equal(suite.T(), "kafka-connection", itemsFile.Items[4].Name)
+	assert.Equal(suite.T(), database.NoLicense, itemsFile.Items[4].License)
+}
+
+func (suite *CrawlTestSuite) TestCrawlPermalinks() {
+	suite.opts.Permalinks = true
+
+	_, err := suite.client.Crawl(suite.db, ContributionType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 5)
+
+	// Contributions are ordered by type, so the activity comes after the action
+	assert.Equal(suite.T(), "https://github.com/project-flogo/contrib/tree/main/activity/log/", contributions[1].SourceURL)
+	assert.Regexp(suite.T(), "^https://github.com/project-flogo/contrib/tree/[0-9a-f]{40}/activity/log/$", contributions[1].Permalink)
+
+	// Without permalinks the stored permalink is kept
+	suite.opts.Permalinks = false
+	_, err = suite.client.Crawl(suite.db, ContributionType, suite.opts)
+	assert.NoError(suite.T(), err)
+
+	stored, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), contributions[1].Permalink, stored[1].Permalink)
+}
+
+func (suite *CrawlTestSuite) TestCrawlSince() {
+	// The repositories in the fixtures were last pushed to on 2020-04-28
+	suite.opts.Since = time.Date(2020, 4, 29, 0, 0, 0, 0, time.UTC)
+
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Skipped)
+	assert.Equal(suite.T(), 0, result.Inserted)
+
+	// The descriptors of skipped results aren't downloaded
+	assert.Equal(suite.T(), 0, suite.server.Hits("raw"))
+
+	suite.opts.Since = time.Date(2020, 4, 27, 0, 0, 0, 0, time.UTC)
+
+	result, err = suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, result.Skipped)
+	assert.Equal(suite.T(), 2, result.Inserted)
+}
+
+func (suite *CrawlTestSuite) TestCrawlRepositoryFails() {
+	suite.server.FailRepository("retgits/flogo-components")
+
+	// Without the details of the repository the source url can't be built, so the results are not stored
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Failed)
+	assert.Equal(suite.T(), 0, result.Inserted)
+	assert.Equal(suite.T(), 0, suite.server.Hits("raw"))
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), contributions)
+}
+
+func (suite *CrawlTestSuite) TestCrawlTwice() {
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Inserted)
+
+	result, err = suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, result.Inserted)
+	assert.Equal(suite.T(), 2, result.Unchanged)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 2)
+}
+
+func (suite *CrawlTestSuite) TestCrawlPagination() {
+	suite.server.MaxPerPage = 1
+
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CrawlResult{
+		Type:         ActivityType,
+		TotalCount:   2,
+		Pages:        2,
+		PagesVisited: 2,
+		Shards:       1,
+		Inserted:     2,
+	}, result)
+	assert.Equal(suite.T(), 2, suite.server.Hits("search"))
+}
+
+func (suite *CrawlTestSuite) TestCrawlDefaultOptions() {
+	suite.server.MaxPerPage = 1
+
+	// Without a timeout all pages are visited
+	result, err := suite.client.Crawl(suite.db, ActivityType, CrawlOptions{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.PagesVisited)
+	assert.Equal(suite.T(), 2, result.Inserted)
+}
+
+func (suite *CrawlTestSuite) TestCrawlTimeout() {
+	suite.server.MaxPerPage = 1
+	suite.server.ResultCap = 1
+	suite.client.resultCap = 1
+	suite.opts.Timeout = 1
+
+	// The repository was last updated long ago, so the crawl stops after the first page with results instead of
+	// moving on to the next shard
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, result.Inserted)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 1)
+}
+
+func (suite *CrawlTestSuite) TestCrawlConcurrency() {
+	for _, concurrency := range []int{1, 2, 8} {
+		suite.opts.Concurrency = concurrency
+
+		result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 2, result.Inserted+result.Unchanged)
+	}
+
+	// The details of a repository are only fetched once
+	assert.Equal(suite.T(), 1, suite.server.Hits("repos"))
+	assert.Equal(suite.T(), 6, suite.server.Hits("raw"))
+}
+
+func (suite *CrawlTestSuite) TestCrawlSharded() {
+	suite.server.MaxPerPage = 1
+	suite.server.ResultCap = 1
+	suite.client.resultCap = 1
+
+	result, err := suite.client.Crawl(suite.db, ActivityType, suite.opts)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), result.TotalCount)
+	assert.True(suite.T(), result.Shards > 1)
+	assert.Equal(suite.T(), 2, result.Inserted)
+	assert.False(suite.T(), result.Truncated)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), contributions, 2)
+}
+
+func (suite *CrawlTestSuite) TestShardSplit() {
+	s := shard{query: activityQuery}
+	assert.Equal(suite.T(), "filename:activity.json flogo", s.String())
+
+	lower, upper, ok := s.split()
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "filename:activity.json flogo size:0..196608", lower.String())
+	assert.Equal(suite.T(), "filename:activity.json flogo size:196609..393216", upper.String())
+
+	lower, upper, ok = shard{query: activityQuery, bySize: true, minSize: 10, maxSize: 11}.split()
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "filename:activity.json flogo size:10..10", lower.String())
+	assert.Equal(suite.T(), "filename:activity.json flogo size:11..11", upper.String())
+
+	_, _, ok = lower.split()
+	assert.False(suite.T(), ok)
+}
+
+func TestCrawlTestSuite(t *testing.T) {
+	suite.Run(t, new(CrawlTestSuite))
+}
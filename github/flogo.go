@@ -1,23 +1,113 @@
 package github
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strconv"
+)
 
-func UnmarshalFlogoActivity(data []byte) (FlogoActivity, error) {
-	var r FlogoActivity
+// UnmarshalFlogoDescriptor parses a descriptor and moves the fields of the legacy format to their current
+// counterparts. The original content is kept in the Raw field.
+func UnmarshalFlogoDescriptor(data []byte) (FlogoDescriptor, error) {
+	var r FlogoDescriptor
 	err := json.Unmarshal(data, &r)
-	return r, err
+	if err != nil {
+		return r, err
+	}
+	r.Normalize()
+	r.Raw = append(json.RawMessage{}, data...)
+	return r, nil
 }
 
-func (r *FlogoActivity) Marshal() ([]byte, error) {
+func (r *FlogoDescriptor) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
 
-type FlogoActivity struct {
-	Name        string `json:"name"`
-	Type        string `json:"type"`
-	Ref         string `json:"ref"`
-	Version     string `json:"version"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Homepage    string `json:"homepage"`
+// FlogoDescriptor is the descriptor of a Flogo contribution, found in activity.json, trigger.json or descriptor.json.
+// Legacy (v0) descriptors list their fields as inputs, outputs and endpoint, while v1 descriptors use input, output
+// and handler.
+type FlogoDescriptor struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Ref         string      `json:"ref"`
+	Version     string      `json:"version"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Homepage    string      `json:"homepage"`
+	Author      string      `json:"author"`
+	Display     *Display    `json:"display,omitempty"`
+	Settings    []Attribute `json:"settings,omitempty"`
+	Input       []Attribute `json:"input,omitempty"`
+	Output      []Attribute `json:"output,omitempty"`
+	Reply       []Attribute `json:"reply,omitempty"`
+	Handler     *Handler    `json:"handler,omitempty"`
+
+	// Fields of the legacy format, Normalize moves them to Input, Output and Handler
+	Inputs   []Attribute `json:"inputs,omitempty"`
+	Outputs  []Attribute `json:"outputs,omitempty"`
+	Endpoint *Handler    `json:"endpoint,omitempty"`
+
+	// Raw is the descriptor as it was found on GitHub
+	Raw json.RawMessage `json:"-"`
+}
+
+// Display describes how a contribution is shown in the Flogo Web UI
+type Display struct {
+	Category    string `json:"category,omitempty"`
+	Visible     Bool   `json:"visible,omitempty"`
+	Description string `json:"description,omitempty"`
+	SmallIcon   string `json:"smallIcon,omitempty"`
+	LargeIcon   string `json:"largeIcon,omitempty"`
+}
+
+// Handler holds the settings of a trigger handler
+type Handler struct {
+	Settings []Attribute `json:"settings,omitempty"`
+}
+
+// Attribute is a setting, input or output of a contribution. The value and allowed values can be of any type, so they
+// are kept as JSON.
+type Attribute struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Required    Bool            `json:"required,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Allowed     json.RawMessage `json:"allowed,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// Bool is a boolean that can also be written as a string ("true") in a descriptor, which happens a lot in
+// descriptors that are written by hand.
+type Bool bool
+
+// UnmarshalJSON parses true, false and any string strconv.ParseBool understands. Other values are false.
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case bool:
+		*b = Bool(t)
+	case string:
+		p, _ := strconv.ParseBool(t)
+		*b = Bool(p)
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// Normalize moves the inputs, outputs and endpoint of a legacy descriptor to input, output and handler, unless the
+// descriptor has both.
+func (r *FlogoDescriptor) Normalize() {
+	if len(r.Input) == 0 {
+		r.Input = r.Inputs
+	}
+	if len(r.Output) == 0 {
+		r.Output = r.Outputs
+	}
+	if r.Handler == nil {
+		r.Handler = r.Endpoint
+	}
+	r.Inputs, r.Outputs, r.Endpoint = nil, nil, nil
 }
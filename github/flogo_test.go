@@ -0,0 +1,62 @@
+package github
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalLegacyDescriptor(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/repos/retgits/flogo-components/trigger/pubnubsubscriber/trigger.json")
+	assert.NoError(t, err)
+
+	d, err := UnmarshalFlogoDescriptor(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "pubnubsubscriber", d.Name)
+	assert.Equal(t, "flogo:trigger", d.Type)
+	assert.Equal(t, "retgits", d.Author)
+	assert.Len(t, d.Settings, 2)
+	assert.True(t, bool(d.Settings[0].Required))
+	assert.Len(t, d.Output, 1)
+	assert.Equal(t, "message", d.Output[0].Name)
+	assert.Len(t, d.Handler.Settings, 1)
+	assert.Equal(t, "channel", d.Handler.Settings[0].Name)
+	assert.Nil(t, d.Outputs)
+	assert.Nil(t, d.Endpoint)
+	assert.JSONEq(t, string(data), string(d.Raw))
+}
+
+func TestUnmarshalDescriptor(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/repos/project-flogo/contrib/trigger/rest/descriptor.json")
+	assert.NoError(t, err)
+
+	d, err := UnmarshalFlogoDescriptor(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "flogo-rest", d.Name)
+	assert.Len(t, d.Settings, 1)
+	assert.Len(t, d.Output, 2)
+	assert.Len(t, d.Reply, 2)
+	assert.Len(t, d.Handler.Settings, 2)
+	assert.JSONEq(t, `["GET", "POST", "PUT", "PATCH", "DELETE"]`, string(d.Handler.Settings[0].Allowed))
+}
+
+func TestUnmarshalHandwrittenDescriptor(t *testing.T) {
+	d, err := UnmarshalFlogoDescriptor([]byte(`{
+		"name": "handwritten",
+		"display": {"category": "Demo", "visible": "true"},
+		"inputs": [{"name": "a", "required": "true", "value": 1}],
+		"input": [{"name": "b", "required": false}]
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Demo", d.Display.Category)
+	assert.True(t, bool(d.Display.Visible))
+	assert.Len(t, d.Input, 1)
+	assert.Equal(t, "b", d.Input[0].Name)
+
+	var b Bool
+	assert.NoError(t, json.Unmarshal([]byte(`"yes"`), &b))
+	assert.False(t, bool(b))
+	assert.Error(t, json.Unmarshal([]byte(`{`), &b))
+}
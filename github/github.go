@@ -177,7 +177,8 @@ type RepoDetails struct {
 	Archived         bool        `json:"archived"`
 	Disabled         bool        `json:"disabled"`
 	OpenIssuesCount  int64       `json:"open_issues_count"`
-	License          interface{} `json:"license"`
+	License          *License    `json:"license"`
+	Topics           []string    `json:"topics"`
 	Forks            int64       `json:"forks"`
 	OpenIssues       int64       `json:"open_issues"`
 	Watchers         int64       `json:"watchers"`
@@ -185,4 +186,16 @@ type RepoDetails struct {
 	TempCloneToken   interface{} `json:"temp_clone_token"`
 	NetworkCount     int64       `json:"network_count"`
 	SubscribersCount int64       `json:"subscribers_count"`
+
+	// Parent is the repository a fork was created from and Source the repository at the root of the network of
+	// forks, both are only set for forks
+	Parent *RepoDetails `json:"parent,omitempty"`
+	Source *RepoDetails `json:"source,omitempty"`
+}
+
+// License is the license GitHub detected in a repository
+type License struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	SPDXID string `json:"spdx_id"`
 }
@@ -0,0 +1,509 @@
+// Package githubtest provides an in-process stand-in for the parts of the GitHub API that fdio uses, so the crawler
+// can be tested end-to-end without access to the internet.
+//
+// The server serves repositories from a fixture directory. Every directory two levels deep (like
+// retgits/flogo-components) is a repository and every file in it can be found using code search and downloaded as raw
+// content. An optional .repo.json file in the repository directory holds fields that override or extend the
+// repository details returned by the API (like default_branch, fork or stargazers_count).
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultPerPage is the number of search results on a page when the request doesn't specify it
+	DefaultPerPage = 30
+
+	// DefaultMaxPerPage is the maximum number of search results on a page, like on GitHub
+	DefaultMaxPerPage = 100
+
+	// DefaultResultCap is the maximum number of search results that can be paged through, like on GitHub
+	DefaultResultCap = 1000
+
+	// repoFile is the name of the file with repository details in a fixture directory
+	repoFile = ".repo.json"
+)
+
+// Server is a fake GitHub server. The URL of the server is the base URL of the API, RawURL returns the URL to use for
+// raw content.
+type Server struct {
+	*httptest.Server
+
+	// PerPage is the number of search results on a page when the request doesn't specify it
+	PerPage int
+
+	// MaxPerPage is the maximum number of search results on a page, requests that ask for more get this number
+	MaxPerPage int
+
+	// ResultCap is the maximum number of search results that can be paged through, results beyond the cap are only
+	// included in the total count
+	ResultCap int
+
+	mu     sync.Mutex
+	repos  map[string]*repository
+	hits   map[string]int
+	failed map[string]bool
+}
+
+// repository is a repository loaded from the fixture directory
+type repository struct {
+	fullName string
+	id       int
+	commit   string
+	details  map[string]interface{}
+	files    map[string][]byte
+}
+
+// file is a single file matched by a code search
+type file struct {
+	repo *repository
+	path string
+}
+
+// NewServer starts a server that serves the repositories in the fixture directory. The caller should call Close when
+// finished, to shut it down.
+func NewServer(dir string) (*Server, error) {
+	s := &Server{
+		PerPage:    DefaultPerPage,
+		MaxPerPage: DefaultMaxPerPage,
+		ResultCap:  DefaultResultCap,
+		repos:      make(map[string]*repository),
+		hits:       make(map[string]int),
+		failed:     make(map[string]bool),
+	}
+
+	owners, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixtures: %s", err.Error())
+	}
+
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+		repos, err := ioutil.ReadDir(filepath.Join(dir, owner.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading fixtures: %s", err.Error())
+		}
+		for _, repo := range repos {
+			if !repo.IsDir() {
+				continue
+			}
+			if err := s.loadRepository(filepath.Join(dir, owner.Name(), repo.Name()), owner.Name()+"/"+repo.Name()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/code", s.handleSearch)
+	mux.HandleFunc("/repos/", s.handleRepos)
+	mux.HandleFunc("/raw/", s.handleRaw)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+// loadRepository reads all files of a repository fixture.
+func (s *Server) loadRepository(dir string, fullName string) error {
+	sum := sha1.Sum([]byte(fullName))
+	repo := &repository{
+		fullName: fullName,
+		id:       len(s.repos) + 1,
+		commit:   hex.EncodeToString(sum[:]),
+		details:  make(map[string]interface{}),
+		files:    make(map[string][]byte),
+	}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if rel == repoFile {
+			return json.Unmarshal(content, &repo.details)
+		}
+		repo.files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error loading fixture %s: %s", fullName, err.Error())
+	}
+
+	s.repos[fullName] = repo
+	return nil
+}
+
+// RawURL returns the URL to use as the raw content URL of the GitHub client.
+func (s *Server) RawURL() string {
+	return s.URL + "/raw"
+}
+
+// WriteFile adds a file to a repository or replaces its content, like a commit that changes it would.
+func (s *Server) WriteFile(fullName string, p string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if repo, ok := s.repos[fullName]; ok {
+		repo.files[p] = content
+	}
+}
+
+// RemoveFile deletes a file from a repository, like a commit that removes it would.
+func (s *Server) RemoveFile(fullName string, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if repo, ok := s.repos[fullName]; ok {
+		delete(repo.files, p)
+	}
+}
+
+// RemoveRepository deletes a repository and all files in it.
+func (s *Server) RemoveRepository(fullName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.repos, fullName)
+}
+
+// FailRepository makes requests for the details of a repository fail, while its files can still be found and
+// downloaded.
+func (s *Server) FailRepository(fullName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[fullName] = true
+}
+
+// Hits returns the number of requests the server received for an endpoint, which is either search, repos or raw.
+func (s *Server) Hits(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[endpoint]
+}
+
+func (s *Server) hit(endpoint string) {
+	s.mu.Lock()
+	s.hits[endpoint]++
+	s.mu.Unlock()
+}
+
+// repositoryJSON returns the repository details as the GitHub API would return them.
+func (s *Server) repositoryJSON(repo *repository) map[string]interface{} {
+	owner := strings.SplitN(repo.fullName, "/", 2)[0]
+	details := map[string]interface{}{
+		"id":        repo.id,
+		"name":      path.Base(repo.fullName),
+		"full_name": repo.fullName,
+		"owner": map[string]interface{}{
+			"login": owner,
+			"type":  "User",
+		},
+		"html_url":       fmt.Sprintf("https://github.com/%s", repo.fullName),
+		"url":            fmt.Sprintf("%s/repos/%s", s.URL, repo.fullName),
+		"fork":           false,
+		"created_at":     "2018-01-01T00:00:00Z",
+		"updated_at":     "2020-04-28T00:00:00Z",
+		"pushed_at":      "2020-04-28T00:00:00Z",
+		"default_branch": "master",
+	}
+	for k, v := range repo.details {
+		details[k] = v
+	}
+	return details
+}
+
+// handleSearch emulates the code search API.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	s.hit("search")
+
+	q, err := parseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	matches := s.search(q)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = s.PerPage
+	}
+	if perPage > s.MaxPerPage {
+		perPage = s.MaxPerPage
+	}
+
+	// Only the results up to the cap can be paged through
+	available := len(matches)
+	if available > s.ResultCap {
+		available = s.ResultCap
+	}
+	lastPage := (available + perPage - 1) / perPage
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	items := make([]interface{}, 0)
+	for idx := (page - 1) * perPage; idx < page*perPage && idx < available; idx++ {
+		items = append(items, s.itemJSON(matches[idx]))
+	}
+
+	var links []string
+	link := func(p int, rel string) {
+		u := *r.URL
+		values := u.Query()
+		values.Set("page", strconv.Itoa(p))
+		u.RawQuery = values.Encode()
+		links = append(links, fmt.Sprintf(`<%s%s>; rel="%s"`, s.URL, u.RequestURI(), rel))
+	}
+	if page > 1 {
+		link(page-1, "prev")
+	}
+	if page < lastPage {
+		link(page+1, "next")
+		link(lastPage, "last")
+	}
+	if page > 1 {
+		link(1, "first")
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"total_count":        len(matches),
+		"incomplete_results": false,
+		"items":              items,
+	})
+}
+
+// search returns all files that match the query, ordered by repository and path.
+func (s *Server) search(q query) []file {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matches []file
+	for _, repo := range s.repos {
+		for p, content := range repo.files {
+			if q.matches(repo.fullName, p, content) {
+				matches = append(matches, file{repo: repo, path: p})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].repo.fullName != matches[j].repo.fullName {
+			return matches[i].repo.fullName < matches[j].repo.fullName
+		}
+		return matches[i].path < matches[j].path
+	})
+
+	return matches
+}
+
+// itemJSON returns a code search result for the file.
+func (s *Server) itemJSON(f file) map[string]interface{} {
+	sum := sha1.Sum(f.repo.files[f.path])
+	repo := s.repositoryJSON(f.repo)
+	return map[string]interface{}{
+		"name":       path.Base(f.path),
+		"path":       f.path,
+		"sha":        hex.EncodeToString(sum[:]),
+		"url":        fmt.Sprintf("%s/repositories/%d/contents/%s?ref=%s", s.URL, f.repo.id, f.path, f.repo.commit),
+		"git_url":    fmt.Sprintf("%s/repositories/%d/git/blobs/%s", s.URL, f.repo.id, hex.EncodeToString(sum[:])),
+		"html_url":   fmt.Sprintf("%s/blob/%s/%s", repo["html_url"], f.repo.commit, f.path),
+		"repository": repo,
+	}
+}
+
+// handleRepos emulates the API that returns the details of a repository.
+func (s *Server) handleRepos(w http.ResponseWriter, r *http.Request) {
+	s.hit("repos")
+
+	fullName := strings.TrimPrefix(r.URL.Path, "/repos/")
+
+	s.mu.Lock()
+	repo, ok := s.repos[fullName]
+	failed := s.failed[fullName]
+	s.mu.Unlock()
+
+	if !ok || failed {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, s.repositoryJSON(repo))
+}
+
+// handleRaw serves the content of files as /raw/{owner}/{repo}/{ref}/{path}, where ref is either the commit of the
+// repository or its default branch.
+func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
+	s.hit("raw")
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/raw/"), "/", 4)
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, ok := s.repos[parts[0]+"/"+parts[1]]
+	if !ok || (parts[2] != repo.commit && parts[2] != s.repositoryJSON(repo)["default_branch"]) {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := repo.files[parts[3]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Write(content)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// query is a parsed code search query
+type query struct {
+	terms      []string
+	qualifiers map[string][]string
+}
+
+// parseQuery splits a code search query into free text terms and qualifiers (like filename:activity.json). Terms can
+// be quoted to search for a phrase.
+func parseQuery(q string) (query, error) {
+	parsed := query{qualifiers: make(map[string][]string)}
+
+	var tokens []string
+	for len(q) > 0 {
+		q = strings.TrimLeft(q, " ")
+		if len(q) == 0 {
+			break
+		}
+		if q[0] == '"' {
+			end := strings.Index(q[1:], `"`)
+			if end == -1 {
+				return parsed, fmt.Errorf("unterminated phrase in query")
+			}
+			tokens = append(tokens, q[:end+2])
+			q = q[end+2:]
+			continue
+		}
+		end := strings.Index(q, " ")
+		if end == -1 {
+			end = len(q)
+		}
+		tokens = append(tokens, q[:end])
+		q = q[end:]
+	}
+
+	for _, token := range tokens {
+		if idx := strings.Index(token, ":"); idx > 0 && !strings.HasPrefix(token, `"`) {
+			key := strings.ToLower(token[:idx])
+			parsed.qualifiers[key] = append(parsed.qualifiers[key], token[idx+1:])
+			continue
+		}
+		parsed.terms = append(parsed.terms, strings.ToLower(strings.Trim(token, `"`)))
+	}
+
+	if len(parsed.terms) == 0 && len(parsed.qualifiers) == 0 {
+		return parsed, fmt.Errorf("validation failed: query is empty")
+	}
+
+	return parsed, nil
+}
+
+// matches returns true if the file matches all terms and qualifiers of the query.
+func (q query) matches(fullName string, p string, content []byte) bool {
+	lower := strings.ToLower(string(content))
+	for _, term := range q.terms {
+		if !strings.Contains(lower, term) {
+			return false
+		}
+	}
+
+	for key, values := range q.qualifiers {
+		for _, value := range values {
+			var ok bool
+			switch key {
+			case "filename":
+				ok = path.Base(p) == value
+			case "path":
+				ok = strings.HasPrefix(p, strings.TrimPrefix(value, "/"))
+			case "user", "org":
+				ok = strings.EqualFold(strings.SplitN(fullName, "/", 2)[0], value)
+			case "repo":
+				ok = strings.EqualFold(fullName, value)
+			case "size":
+				ok = matchesSize(value, len(content))
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchesSize returns true if the size matches the value of a size qualifier, which is either a number, a range
+// (like 10..20 or 10..*) or a comparison (like >10 or <=20).
+func matchesSize(value string, size int) bool {
+	if idx := strings.Index(value, ".."); idx > -1 {
+		min, max := value[:idx], value[idx+2:]
+		return (min == "*" || matchesSize(">="+min, size)) && (max == "*" || matchesSize("<="+max, size))
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(value, op) {
+			n, err := strconv.Atoi(value[len(op):])
+			if err != nil {
+				return false
+			}
+			switch op {
+			case ">=":
+				return size >= n
+			case "<=":
+				return size <= n
+			case ">":
+				return size > n
+			default:
+				return size < n
+			}
+		}
+	}
+
+	n, err := strconv.Atoi(value)
+	return err == nil && size == n
+}
@@ -6,105 +6,316 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/tomnomnom/linkheader"
 )
 
-// GithubData contains repositories with data and HTTP headers
+const (
+	// DefaultBaseURL is the URL of the public GitHub API
+	DefaultBaseURL = "https://api.github.com"
+
+	// DefaultRawURL is the URL from which the raw content of files on public GitHub is downloaded
+	DefaultRawURL = "https://raw.githubusercontent.com"
+
+	// defaultMaxRetries is the number of times a request is retried when GitHub responds with a transient error
+	defaultMaxRetries = 5
+
+	// maxBackoff is the longest time the client waits between two retries when GitHub doesn't say how long to wait
+	maxBackoff = time.Minute
+)
+
+// GithubData contains a page of search results with data and HTTP headers
 type GithubData struct {
 	Items       []Item
 	HTTPHeaders http.Header
+
+	// TotalCount is the number of files that match the search
+	TotalCount int64
+
+	// NextURL is the URL of the next page of results, it is empty on the last page
+	NextURL string
+
+	// LastPage is the number of the last page of results, it is zero when all results fit on a single page
+	LastPage int
 }
 
-func getSearchResults(url string, token string) (GithubData, error) {
-	log.Printf("sending request to: %s", url)
+// ClientOptions configures how a Client connects to GitHub. Fields that are left empty use the defaults for public
+// GitHub, so a GitHub Enterprise host or a test server only needs to override the URLs.
+type ClientOptions struct {
+	// Token is the personal access token used to authenticate requests
+	Token string
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return GithubData{}, fmt.Errorf("error creating newrequest: %s", err.Error())
+	// BaseURL is the URL of the GitHub API (like https://github.example.com/api/v3), defaults to DefaultBaseURL
+	BaseURL string
+
+	// RawURL is the URL from which the raw content of files is downloaded (like https://github.example.com/raw),
+	// defaults to DefaultRawURL
+	RawURL string
+
+	// HTTPClient is used to send all requests, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Client sends requests to GitHub. The client keeps track of the rate limits GitHub reports in the response headers
+// and waits until the limit resets instead of sending requests that are bound to fail. Responses that indicate a
+// transient problem (server errors and secondary rate limits) are retried with an increasing delay.
+type Client struct {
+	token      string
+	baseURL    string
+	rawURL     string
+	httpClient *http.Client
+	maxRetries int
+	resultCap  int
+
+	// mu guards limits, which holds for each rate limit resource (like search or core) the time until which no
+	// requests can be sent because the limit is exhausted, and repos, which caches the details of repositories
+	mu     sync.Mutex
+	limits map[string]time.Time
+	repos  map[string]RepoDetails
+
+	// now and sleep are replaced in tests so they don't have to wait for real
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// StatusError is returned when GitHub responds with an http status that indicates the request failed
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("github respondes with http status %d: %s", e.StatusCode, e.Status)
+}
+
+// NewClient creates a new client using the options.
+func NewClient(opts ClientOptions) *Client {
+	if len(opts.BaseURL) == 0 {
+		opts.BaseURL = DefaultBaseURL
 	}
+	if len(opts.RawURL) == 0 {
+		opts.RawURL = DefaultRawURL
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	return &Client{
+		token:      opts.Token,
+		baseURL:    strings.TrimSuffix(opts.BaseURL, "/"),
+		rawURL:     strings.TrimSuffix(opts.RawURL, "/"),
+		httpClient: opts.HTTPClient,
+		maxRetries: defaultMaxRetries,
+		resultCap:  SearchResultCap,
+		limits:     make(map[string]time.Time),
+		repos:      make(map[string]RepoDetails),
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+}
 
-	req.Header.Add("authorization", fmt.Sprintf("token %s", token))
+// get sends a GET request to the url and returns the body and headers of the response. Before the request is sent the
+// client waits until the rate limit that applies to the url allows new requests.
+func (c *Client) get(url string) ([]byte, http.Header, error) {
+	resource := rateLimitResource(url)
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return GithubData{}, fmt.Errorf("error sending httprequest: %s", err.Error())
+	for attempt := 0; ; attempt++ {
+		c.waitForRateLimit(resource)
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating newrequest: %s", err.Error())
+		}
+
+		if len(c.token) > 0 {
+			req.Header.Add("authorization", fmt.Sprintf("token %s", c.token))
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error sending httprequest: %s", err.Error())
+		}
+
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading http response: %s", err.Error())
+		}
+
+		c.updateRateLimit(resource, res.Header)
+
+		if res.StatusCode >= 200 && res.StatusCode <= 299 {
+			return body, res.Header, nil
+		}
+
+		wait, retry := c.retryDelay(res, body, attempt)
+		if !retry || attempt >= c.maxRetries {
+			return nil, nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+		}
+
+		log.Printf("github responds with http status %d, retrying %s in %s", res.StatusCode, url, wait)
+		c.sleep(wait)
+	}
+}
+
+// rateLimitResource returns the name of the rate limit that GitHub applies to requests for the url. Searches have a
+// separate, much lower, limit than the other API calls.
+func rateLimitResource(url string) string {
+	if strings.Contains(url, "/search/") {
+		return "search"
 	}
+	return "core"
+}
 
-	defer res.Body.Close()
+// waitForRateLimit blocks until the rate limit for the resource allows new requests.
+func (c *Client) waitForRateLimit(resource string) {
+	c.mu.Lock()
+	until := c.limits[resource]
+	c.mu.Unlock()
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return GithubData{}, fmt.Errorf("error reading http response: %s", err.Error())
+	if wait := until.Sub(c.now()); wait > 0 {
+		log.Printf("github %s rate limit reached, waiting %s until it resets", resource, wait.Round(time.Second))
+		c.sleep(wait)
 	}
+}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return GithubData{}, fmt.Errorf("github respondes with http status %d: %s", res.StatusCode, res.Status)
+// updateRateLimit records when the rate limit for the resource resets if the response headers say there are no
+// requests left.
+func (c *Client) updateRateLimit(resource string, h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > 0 {
+		return
 	}
 
-	githubSearchData, err := UnmarshalGithubSearchData(body)
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
 	if err != nil {
-		return GithubData{}, fmt.Errorf("error unmarshalling http response: %s", err.Error())
+		return
 	}
 
-	return GithubData{
-		Items:       githubSearchData.Items,
-		HTTPHeaders: res.Header,
-	}, nil
+	c.mu.Lock()
+	// Wait an extra second to account for clock differences between GitHub and this machine
+	c.limits[resource] = time.Unix(reset, 0).Add(time.Second)
+	c.mu.Unlock()
 }
 
-func getActivityContent(url string) (FlogoActivity, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return FlogoActivity{}, fmt.Errorf("error creating newrequest: %s", err.Error())
+// retryDelay determines whether a failed request should be retried and how long to wait before doing so.
+func (c *Client) retryDelay(res *http.Response, body []byte, attempt int) (time.Duration, bool) {
+	switch {
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests:
+		// GitHub tells explicitly how long to wait when a secondary rate limit is hit
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		// The primary rate limit is exhausted, updateRateLimit has recorded when it resets
+		if res.Header.Get("X-RateLimit-Remaining") == "0" {
+			return 0, true
+		}
+		msg := strings.ToLower(string(body))
+		if strings.Contains(msg, "abuse") || strings.Contains(msg, "secondary rate limit") {
+			return backoff(attempt), true
+		}
+		return 0, false
+	case res.StatusCode >= 500:
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		return backoff(attempt), true
+	default:
+		return 0, false
 	}
+}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return FlogoActivity{}, fmt.Errorf("error sending httprequest: %s", err.Error())
+// backoff returns the time to wait before the next attempt, which doubles with every attempt.
+func backoff(attempt int) time.Duration {
+	wait := time.Second << uint(attempt)
+	if wait > maxBackoff || wait <= 0 {
+		return maxBackoff
 	}
+	return wait
+}
 
-	defer res.Body.Close()
+// apiURL returns the full URL of an API endpoint, like search/code or repos/retgits/fdio.
+func (c *Client) apiURL(path string) string {
+	return fmt.Sprintf("%s/%s", c.baseURL, path)
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return FlogoActivity{}, fmt.Errorf("error reading http response: %s", err.Error())
+// rawContentURL returns the URL from which the content of the file found by a code search can be downloaded.
+func (c *Client) rawContentURL(item Item) string {
+	return c.rawFileURL(item.Repository.FullName, commitRef(item), item.Path)
+}
+
+// rawFileURL returns the URL from which the content of the file at the path in the repository can be downloaded. The
+// ref is either a commit or a branch.
+func (c *Client) rawFileURL(fullName string, ref string, path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for idx := range segments {
+		segments[idx] = url.PathEscape(segments[idx])
 	}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return FlogoActivity{}, fmt.Errorf("github respondes with http status %d: %s", res.StatusCode, res.Status)
+	return fmt.Sprintf("%s/%s/%s/%s", c.rawURL, fullName, ref, strings.Join(segments, "/"))
+}
+
+// commitRef returns the commit the file found by a code search was found in. The commit is taken from the ref
+// parameter of the API URL of the item, or from the HTML URL if the API URL doesn't have one.
+func commitRef(item Item) string {
+	if u, err := url.Parse(item.URL); err == nil {
+		if ref := u.Query().Get("ref"); len(ref) > 0 {
+			return ref
+		}
 	}
+	if idx := strings.Index(item.HTMLURL, "/blob/"); idx > -1 {
+		return strings.SplitN(item.HTMLURL[idx+len("/blob/"):], "/", 2)[0]
+	}
+	return ""
+}
 
-	activity, err := UnmarshalFlogoActivity(body)
+func (c *Client) getSearchResults(url string) (GithubData, error) {
+	log.Printf("sending request to: %s", url)
+
+	body, headers, err := c.get(url)
 	if err != nil {
-		return FlogoActivity{}, fmt.Errorf("error unmarshalling http response: %s", err.Error())
+		return GithubData{}, err
 	}
 
-	return activity, nil
+	githubSearchData, err := UnmarshalGithubSearchData(body)
+	if err != nil {
+		return GithubData{}, fmt.Errorf("error unmarshalling http response: %s", err.Error())
+	}
+
+	nextURL, lastPage := parseLinks(headers)
+
+	return GithubData{
+		Items:       githubSearchData.Items,
+		HTTPHeaders: headers,
+		TotalCount:  githubSearchData.TotalCount,
+		NextURL:     nextURL,
+		LastPage:    lastPage,
+	}, nil
 }
 
-func getRepoDetails(url string) (RepoDetails, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) getDescriptor(url string) (FlogoDescriptor, error) {
+	body, _, err := c.get(url)
 	if err != nil {
-		return RepoDetails{}, fmt.Errorf("error creating newrequest: %s", err.Error())
+		return FlogoDescriptor{}, err
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	descriptor, err := UnmarshalFlogoDescriptor(body)
 	if err != nil {
-		return RepoDetails{}, fmt.Errorf("error sending httprequest: %s", err.Error())
+		return FlogoDescriptor{}, &InvalidDescriptorError{Err: err}
 	}
 
-	defer res.Body.Close()
+	return descriptor, nil
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+func (c *Client) getRepoDetails(url string) (RepoDetails, error) {
+	body, _, err := c.get(url)
 	if err != nil {
-		return RepoDetails{}, fmt.Errorf("error reading http response: %s", err.Error())
-	}
-
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return RepoDetails{}, fmt.Errorf("github respondes with http status %d: %s", res.StatusCode, res.Status)
+		return RepoDetails{}, err
 	}
 
 	repoDetails, err := UnmarshalRepoDetails(body)
@@ -115,20 +326,23 @@ func getRepoDetails(url string) (RepoDetails, error) {
 	return repoDetails, nil
 }
 
-func getMaxPages(h http.Header) int {
-	p := 0
+// parseLinks returns the URL of the next page and the number of the last page from the Link header GitHub uses to
+// paginate results. Link headers are formatted as
+// <https://api.github.com/search/code?q=flogo&page=2>; rel="next", <https://api.github.com/search/code?q=flogo&page=33>; rel="last"
+func parseLinks(h http.Header) (string, int) {
+	var next string
+	var last int
 
-	links := linkheader.Parse(h.Get("Link"))
-
-	for _, link := range links {
-		if link.Rel == "last" {
-			// Get the last page number
-			// Link headers are formatted as https://api.github.com/search/code?q=flogo%2Bfilename%3Aactivity.json&page=33
-			p, _ = strconv.Atoi(link.URL[strings.Index(link.URL, "page=")+5:])
+	for _, link := range linkheader.Parse(h.Get("Link")) {
+		switch link.Rel {
+		case "next":
+			next = link.URL
+		case "last":
+			if u, err := url.Parse(link.URL); err == nil {
+				last, _ = strconv.Atoi(u.Query().Get("page"))
+			}
 		}
-
 	}
 
-	log.Printf("There are a total of %d pages", p)
-	return p
+	return next, last
 }
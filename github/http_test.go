@@ -0,0 +1,146 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPTestSuite struct {
+	suite.Suite
+	now    time.Time
+	slept  []time.Duration
+	client *Client
+}
+
+func (suite *HTTPTestSuite) SetupTest() {
+	suite.now = time.Unix(1588000000, 0)
+	suite.slept = nil
+}
+
+// newClient returns a client that sends requests to the handler and records how long it sleeps instead of sleeping.
+func (suite *HTTPTestSuite) newClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	client := NewClient(ClientOptions{Token: "token", BaseURL: server.URL, HTTPClient: server.Client()})
+	client.now = func() time.Time { return suite.now }
+	client.sleep = func(d time.Duration) {
+		suite.slept = append(suite.slept, d)
+		suite.now = suite.now.Add(d)
+	}
+	return client, server
+}
+
+func (suite *HTTPTestSuite) TestWaitForRateLimitReset() {
+	reset := suite.now.Add(30 * time.Second)
+	client, server := suite.newClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(suite.T(), "token token", r.Header.Get("authorization"))
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		fmt.Fprint(w, "{}")
+	})
+	defer server.Close()
+
+	_, _, err := client.get(client.apiURL("search/code"))
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), suite.slept)
+
+	// The search limit is exhausted, the core limit is not
+	_, _, err = client.get(client.apiURL("repos/retgits/fdio"))
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), suite.slept)
+
+	_, _, err = client.get(client.apiURL("search/code"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []time.Duration{31 * time.Second}, suite.slept)
+}
+
+func (suite *HTTPTestSuite) TestRetryAfter() {
+	requests := 0
+	client, server := suite.newClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit."}`)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	})
+	defer server.Close()
+
+	_, _, err := client.get(client.apiURL("search/code"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, requests)
+	assert.Equal(suite.T(), []time.Duration{time.Minute}, suite.slept)
+}
+
+func (suite *HTTPTestSuite) TestRetryWithBackoff() {
+	requests := 0
+	client, server := suite.newClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "You have triggered an abuse detection mechanism."}`)
+		default:
+			fmt.Fprint(w, "{}")
+		}
+	})
+	defer server.Close()
+
+	_, _, err := client.get(client.apiURL("search/code"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, requests)
+	assert.Equal(suite.T(), []time.Duration{time.Second, 2 * time.Second}, suite.slept)
+}
+
+func (suite *HTTPTestSuite) TestGiveUp() {
+	requests := 0
+	client, server := suite.newClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer server.Close()
+
+	_, _, err := client.get(client.apiURL("search/code"))
+	assert.EqualError(suite.T(), err, "github respondes with http status 503: 503 Service Unavailable")
+	assert.Equal(suite.T(), defaultMaxRetries+1, requests)
+
+	requests = 0
+	client, server = suite.newClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	_, _, err = client.get(client.apiURL("repos/retgits/fdio"))
+	assert.EqualError(suite.T(), err, "github respondes with http status 404: 404 Not Found")
+	assert.Equal(suite.T(), 1, requests)
+}
+
+func (suite *HTTPTestSuite) TestRawContentURL() {
+	client := NewClient(ClientOptions{RawURL: "https://github.example.com/raw/"})
+
+	item := Item{
+		Path:       "activity/my activity/activity.json",
+		URL:        "https://api.github.com/repositories/1/contents/activity/my%20activity/activity.json?ref=abc123",
+		HTMLURL:    "https://github.com/retgits/flogo-components/blob/abc123/activity/my%20activity/activity.json",
+		Repository: Repository{FullName: "retgits/flogo-components"},
+	}
+	assert.Equal(suite.T(), "https://github.example.com/raw/retgits/flogo-components/abc123/activity/my%20activity/activity.json", client.rawContentURL(item))
+
+	item.URL = ""
+	assert.Equal(suite.T(), "https://github.example.com/raw/retgits/flogo-components/abc123/activity/my%20activity/activity.json", client.rawContentURL(item))
+}
+
+func TestHTTPTestSuite(t *testing.T) {
+	suite.Run(t, new(HTTPTestSuite))
+}
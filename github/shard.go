@@ -0,0 +1,46 @@
+package github
+
+import "fmt"
+
+// maxIndexedFileSize is the size in bytes of the largest file GitHub indexes for code search. Larger files can't be
+// found, so a search split by file size never needs to look beyond this size.
+const maxIndexedFileSize = 384 * 1024
+
+// shard is a code search that is restricted to files within a range of sizes. Splitting a search into shards with
+// smaller size ranges makes each shard match fewer files, which gets past the maximum number of results GitHub
+// returns for a single search.
+type shard struct {
+	query   string
+	bySize  bool
+	minSize int
+	maxSize int
+}
+
+// sharded returns true if the search is restricted by file size.
+func (s shard) sharded() bool {
+	return s.bySize
+}
+
+// String returns the code search query of the shard.
+func (s shard) String() string {
+	if !s.sharded() {
+		return s.query
+	}
+	return fmt.Sprintf("%s size:%d..%d", s.query, s.minSize, s.maxSize)
+}
+
+// split divides the size range of the shard into two halves. A search that isn't sharded yet is split into two halves
+// of all file sizes GitHub indexes. It returns false if the shard covers a single size and can't be split.
+func (s shard) split() (shard, shard, bool) {
+	if !s.sharded() {
+		s.bySize, s.minSize, s.maxSize = true, 0, maxIndexedFileSize
+	}
+	if s.minSize >= s.maxSize {
+		return s, s, false
+	}
+
+	mid := s.minSize + (s.maxSize-s.minSize)/2
+	lower := shard{query: s.query, bySize: true, minSize: s.minSize, maxSize: mid}
+	upper := shard{query: s.query, bySize: true, minSize: mid + 1, maxSize: s.maxSize}
+	return lower, upper, true
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rules checked by the validator, a finding reports which rule a descriptor breaks
+const (
+	JSONRule    = "json"
+	NameRule    = "name"
+	RefRule     = "ref"
+	VersionRule = "version"
+	TypeRule    = "type"
+)
+
+// semver matches a semantic version (https://semver.org), with an optional v in front of it
+var semver = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// Finding is a problem in a descriptor
+type Finding struct {
+	Rule    string
+	Message string
+}
+
+// InvalidDescriptorError is returned when a file that was found on GitHub is not a valid descriptor
+type InvalidDescriptorError struct {
+	Err error
+}
+
+func (e *InvalidDescriptorError) Error() string {
+	return fmt.Sprintf("error unmarshalling http response: %s", e.Err.Error())
+}
+
+// ValidateDescriptor checks the descriptor found in the file at the path in the repository with the full name (like
+// retgits/flogo-components). The descriptor must have a name and a semantic version, its ref must point to the
+// directory of the file and its type must match the name of the file. Only the legacy activity.json and trigger.json
+// files must have a ref, the ref of a descriptor.json comes from the import path so it's only checked when it's set.
+func ValidateDescriptor(d FlogoDescriptor, fullName string, filePath string) []Finding {
+	var findings []Finding
+
+	if len(strings.TrimSpace(d.Name)) == 0 {
+		findings = append(findings, Finding{Rule: NameRule, Message: "name is missing"})
+	}
+
+	expectedRef := "github.com/" + fullName
+	if dir := path.Dir(filePath); dir != "." {
+		expectedRef = expectedRef + "/" + dir
+	}
+	legacy := path.Base(filePath) == "activity.json" || path.Base(filePath) == "trigger.json"
+	switch {
+	case len(strings.TrimSpace(d.Ref)) == 0 && !legacy:
+		// The ref of a descriptor.json is the import path of the package it's in
+	case len(strings.TrimSpace(d.Ref)) == 0:
+		findings = append(findings, Finding{Rule: RefRule, Message: fmt.Sprintf("ref is missing, expected %s", expectedRef)})
+	case !strings.EqualFold(strings.TrimSuffix(d.Ref, "/"), expectedRef):
+		findings = append(findings, Finding{Rule: RefRule, Message: fmt.Sprintf("ref %s does not match the location of the descriptor, expected %s", d.Ref, expectedRef)})
+	}
+
+	switch {
+	case len(strings.TrimSpace(d.Version)) == 0:
+		findings = append(findings, Finding{Rule: VersionRule, Message: "version is missing"})
+	case !semver.MatchString(d.Version):
+		findings = append(findings, Finding{Rule: VersionRule, Message: fmt.Sprintf("version %s is not a semantic version", d.Version)})
+	}
+
+	kind, ok := descriptorKind(d.Type)
+	switch {
+	case len(strings.TrimSpace(d.Type)) == 0:
+		findings = append(findings, Finding{Rule: TypeRule, Message: "type is missing"})
+	case !ok:
+		findings = append(findings, Finding{Rule: TypeRule, Message: fmt.Sprintf("type %s is unknown", d.Type)})
+	case path.Base(filePath) == "activity.json" && kind != ActivityType:
+		findings = append(findings, Finding{Rule: TypeRule, Message: fmt.Sprintf("type %s does not match activity.json, expected flogo:activity", d.Type)})
+	case path.Base(filePath) == "trigger.json" && kind != TriggerType:
+		findings = append(findings, Finding{Rule: TypeRule, Message: fmt.Sprintf("type %s does not match trigger.json, expected flogo:trigger", d.Type)})
+	}
+
+	return findings
+}
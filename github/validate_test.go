@@ -0,0 +1,107 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDescriptor(t *testing.T) {
+	valid := FlogoDescriptor{
+		Name:    "hello",
+		Type:    "flogo:activity",
+		Ref:     "github.com/retgits/flogo-components/activity/hello",
+		Version: "0.0.1",
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(d *FlogoDescriptor)
+		path     string
+		findings []Finding
+	}{
+		{
+			name: "valid",
+			path: "activity/hello/activity.json",
+		},
+		{
+			name: "valid with a prerelease version and a differently cased ref",
+			modify: func(d *FlogoDescriptor) {
+				d.Version = "v1.0.0-beta.1"
+				d.Ref = "github.com/Retgits/flogo-components/activity/hello/"
+			},
+			path: "activity/hello/activity.json",
+		},
+		{
+			name:   "missing fields",
+			modify: func(d *FlogoDescriptor) { *d = FlogoDescriptor{} },
+			path:   "activity/hello/activity.json",
+			findings: []Finding{
+				{Rule: NameRule, Message: "name is missing"},
+				{Rule: RefRule, Message: "ref is missing, expected github.com/retgits/flogo-components/activity/hello"},
+				{Rule: VersionRule, Message: "version is missing"},
+				{Rule: TypeRule, Message: "type is missing"},
+			},
+		},
+		{
+			name:   "wrong ref",
+			modify: func(d *FlogoDescriptor) { d.Ref = "github.com/retgits/flogo-components/activity/writetofile" },
+			path:   "activity/hello/activity.json",
+			findings: []Finding{
+				{Rule: RefRule, Message: "ref github.com/retgits/flogo-components/activity/writetofile does not match the location of the descriptor, expected github.com/retgits/flogo-components/activity/hello"},
+			},
+		},
+		{
+			name:   "descriptor in the root of the repository",
+			modify: func(d *FlogoDescriptor) { d.Type = "flogo:action"; d.Ref = "github.com/retgits/flogo-components" },
+			path:   "descriptor.json",
+		},
+		{
+			name:   "descriptor without a ref",
+			modify: func(d *FlogoDescriptor) { d.Ref = "" },
+			path:   "activity/hello/descriptor.json",
+		},
+		{
+			name:   "descriptor with a wrong ref",
+			modify: func(d *FlogoDescriptor) { d.Ref = "github.com/retgits/flogo-components/activity/writetofile" },
+			path:   "activity/hello/descriptor.json",
+			findings: []Finding{
+				{Rule: RefRule, Message: "ref github.com/retgits/flogo-components/activity/writetofile does not match the location of the descriptor, expected github.com/retgits/flogo-components/activity/hello"},
+			},
+		},
+		{
+			name:   "version is not semver",
+			modify: func(d *FlogoDescriptor) { d.Version = "1.0" },
+			path:   "activity/hello/activity.json",
+			findings: []Finding{
+				{Rule: VersionRule, Message: "version 1.0 is not a semantic version"},
+			},
+		},
+		{
+			name:   "type does not match the file",
+			modify: func(d *FlogoDescriptor) { d.Type = "flogo:trigger" },
+			path:   "activity/hello/activity.json",
+			findings: []Finding{
+				{Rule: TypeRule, Message: "type flogo:trigger does not match activity.json, expected flogo:activity"},
+			},
+		},
+		{
+			name:   "unknown type",
+			modify: func(d *FlogoDescriptor) { d.Type = "flogo:model" },
+			path:   "activity/hello/descriptor.json",
+			findings: []Finding{
+				{Rule: TypeRule, Message: "type flogo:model is unknown"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := valid
+			if test.modify != nil {
+				test.modify(&d)
+			}
+			assert.Equal(t, test.findings, ValidateDescriptor(d, "retgits/flogo-components", test.path))
+		})
+	}
+}
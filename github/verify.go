@@ -0,0 +1,150 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retgits/fdio/database"
+)
+
+// VerifyResult summarizes what a verification has found
+type VerifyResult struct {
+	// Checked is the number of contributions that were verified
+	Checked int
+
+	// Present and Missing count the contributions whose descriptor still exists and no longer exists, Failed counts
+	// the contributions that could not be verified (because their url isn't a GitHub url or GitHub responded with an
+	// error) and are left as they were
+	Present int
+	Missing int
+	Failed  int
+}
+
+// Verify checks for every contribution in the database whether its descriptor still exists on the branch its source
+// url points to, and marks the contribution as present or missing. Up to concurrency descriptors are checked at the
+// same time, the database is updated one contribution at a time.
+func (c *Client) Verify(db *database.Database, concurrency int) (VerifyResult, error) {
+	var result VerifyResult
+
+	contributions, err := db.Contributions()
+	if err != nil {
+		return result, err
+	}
+
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	found := make([]bool, len(contributions))
+	errs := make([]error, len(contributions))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(contributions); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				found[idx], errs[idx] = c.descriptorExists(contributions[idx])
+			}
+		}()
+	}
+
+	for idx := range contributions {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	now := time.Now()
+	for idx, contribution := range contributions {
+		result.Checked++
+
+		switch {
+		case errs[idx] != nil:
+			log.Printf("unable to verify %s: %s", contribution.SourceURL, errs[idx].Error())
+			result.Failed++
+			continue
+		case found[idx]:
+			err = db.MarkPresent(contribution.SourceURL, now)
+			result.Present++
+		default:
+			log.Printf("the descriptor of %s (%s) no longer exists", contribution.Name, contribution.SourceURL)
+			err = db.MarkMissing(contribution.SourceURL, now)
+			result.Missing++
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// descriptorExists checks whether the descriptor of the contribution can still be downloaded. As the source url
+// doesn't include the name of the descriptor file, all files the contribution could have been found in are tried,
+// starting with the most likely one.
+func (c *Client) descriptorExists(contribution database.Contribution) (bool, error) {
+	fullName, branch, dir, err := parseSourceURL(contribution.SourceURL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range descriptorFiles(contribution) {
+		_, _, err := c.get(c.rawFileURL(fullName, url.PathEscape(branch), dir+file))
+		if err == nil {
+			return true, nil
+		}
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// descriptorFiles returns the names of the files the descriptor of the contribution can be in.
+func descriptorFiles(contribution database.Contribution) []string {
+	var legacyFile string
+	switch contribution.ContributionType {
+	case ActivityType.String():
+		legacyFile = "activity.json"
+	case TriggerType.String():
+		legacyFile = "trigger.json"
+	default:
+		return []string{"descriptor.json"}
+	}
+
+	if contribution.Legacy {
+		return []string{legacyFile, "descriptor.json"}
+	}
+	return []string{"descriptor.json", legacyFile}
+}
+
+// parseSourceURL splits a source url (like https://github.com/retgits/flogo-components/tree/master/activity/hello/)
+// into the full name of the repository, the branch and the directory of the contribution.
+func parseSourceURL(sourceURL string) (fullName string, branch string, dir string, err error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error parsing %s: %s", sourceURL, err.Error())
+	}
+
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 5)
+	if len(parts) < 4 || parts[2] != "tree" {
+		return "", "", "", fmt.Errorf("%s is not a url of a directory on GitHub", sourceURL)
+	}
+
+	if len(parts) == 5 {
+		dir = strings.TrimSuffix(parts[4], "/") + "/"
+	}
+
+	return parts[0] + "/" + parts[1], parts[3], dir, nil
+}
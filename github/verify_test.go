@@ -0,0 +1,62 @@
+package github
+
+import (
+	"github.com/retgits/fdio/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *CrawlTestSuite) TestVerify() {
+	for _, ci := range []ContributionIdentifier{ActivityType, TriggerType, ContributionType} {
+		_, err := suite.client.Crawl(suite.db, ci, suite.opts)
+		assert.NoError(suite.T(), err)
+	}
+	_, err := suite.db.UpsertContribution(database.Contribution{Name: "elsewhere", SourceURL: "https://example.com/elsewhere"})
+	assert.NoError(suite.T(), err)
+
+	suite.server.RemoveFile("retgits/flogo-components", "activity/hello/activity.json")
+	suite.server.RemoveRepository("project-flogo/flow")
+
+	result, err := suite.client.Verify(suite.db, 2)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), VerifyResult{Checked: 10, Present: 7, Missing: 2, Failed: 1}, result)
+
+	contributions, err := suite.db.Contributions()
+	assert.NoError(suite.T(), err)
+
+	statuses := make(map[string]string)
+	for _, c := range contributions {
+		statuses[c.Name] = c.Status
+		if c.Status == database.StatusMissing {
+			assert.Equal(suite.T(), c.CheckedOn, c.MissingSince)
+		}
+	}
+	assert.Equal(suite.T(), map[string]string{
+		"hello":            database.StatusMissing,
+		"flow":             database.StatusMissing,
+		"writetofile":      database.StatusPresent,
+		"pubnubsubscriber": database.StatusPresent,
+		"flogo-log":        database.StatusPresent,
+		"flogo-rest":       database.StatusPresent,
+		"string":           database.StatusPresent,
+		"kafka-connection": database.StatusPresent,
+		"mqtt":             database.StatusPresent,
+		"elsewhere":        "",
+	}, statuses)
+}
+
+func (suite *CrawlTestSuite) TestParseSourceURL() {
+	fullName, branch, dir, err := parseSourceURL("https://github.com/retgits/flogo-components/tree/master/activity/hello/")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "retgits/flogo-components", fullName)
+	assert.Equal(suite.T(), "master", branch)
+	assert.Equal(suite.T(), "activity/hello/", dir)
+
+	fullName, branch, dir, err = parseSourceURL("https://github.com/project-flogo/flow/tree/master/")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "project-flogo/flow", fullName)
+	assert.Equal(suite.T(), "master", branch)
+	assert.Equal(suite.T(), "", dir)
+
+	_, _, _, err = parseSourceURL("https://github.com/retgits/flogo-components")
+	assert.EqualError(suite.T(), err, "https://github.com/retgits/flogo-components is not a url of a directory on GitHub")
+}
@@ -3,6 +3,7 @@ module github.com/retgits/fdio
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
 	github.com/olekukonko/tablewriter v0.0.4
@@ -0,0 +1,169 @@
+// Package lock implements an advisory lock file that prevents two instances of fdio from writing to the same
+// database at the same time.
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StaleAfter is the age after which a lock is considered stale when it's not possible to check whether the process
+// that holds it is still running, because it runs on another host.
+var StaleAfter = 24 * time.Hour
+
+// Info describes the process that holds a lock
+type Info struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+// Lock is a lock file held by the current process
+type Lock struct {
+	Path string
+	Info Info
+}
+
+// LockedError is returned when the lock is held by another process
+type LockedError struct {
+	Path   string
+	Holder Info
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is locked by process %d on %s since %s", e.Path, e.Holder.PID, e.Holder.Host, e.Holder.Started.Format(time.RFC3339))
+}
+
+// Acquire creates the lock file at path. If another process holds the lock a *LockedError is returned, unless the
+// lock is stale or force is true, in which case the lock is taken over. A lock is stale when the process that holds it
+// no longer runs on this host, or when it was acquired on another host more than StaleAfter ago.
+func Acquire(path string, force bool) (*Lock, error) {
+	host, _ := os.Hostname()
+	info := Info{PID: os.Getpid(), Host: host, Started: time.Now().UTC()}
+
+	content, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("error creating lock: %s", err.Error())
+	}
+
+	// The lock is written to a temporary file that is linked into place, so another process never sees a lock file
+	// without content
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating lock %s: %s", path, err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing lock %s: %s", path, err.Error())
+	}
+
+	// Try twice, the second time after an existing stale lock is removed
+	for attempt := 0; attempt < 2; attempt++ {
+		err := os.Link(tmp.Name(), path)
+		if err == nil {
+			return &Lock{Path: path, Info: info}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("error creating lock %s: %s", path, err.Error())
+		}
+
+		holder, held, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		if !force && !holder.stale(host) {
+			return nil, &LockedError{Path: path, Holder: holder}
+		}
+
+		// Only the lock that was found to be stale is removed, when another process took it over in the meantime
+		// the next attempt finds its lock instead
+		if _, err := remove(path, held); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("error creating lock %s: another process acquired it first", path)
+}
+
+// Read returns the details of the process that holds the lock at path.
+func Read(path string) (Info, error) {
+	info, _, err := read(path)
+	return info, err
+}
+
+// read returns the details of the process that holds the lock at path, together with the content of the lock file.
+func read(path string) (Info, []byte, error) {
+	var info Info
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return info, nil, fmt.Errorf("error reading lock %s: %s", path, err.Error())
+	}
+
+	// A lock that can't be parsed is treated as being held by an unknown process that started long ago
+	if err := json.Unmarshal(content, &info); err != nil {
+		return Info{}, content, nil
+	}
+
+	return info, content, nil
+}
+
+// remove removes the lock file at path if it still has the content, so a lock that another process acquired in the
+// meantime is left alone. The file is first renamed to a name only this process uses, which only one process can do,
+// and put back when it turns out to hold a different lock. It returns true if the lock was removed.
+func remove(path string, content []byte) (bool, error) {
+	taken := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, taken); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error removing lock %s: %s", path, err.Error())
+	}
+	defer os.Remove(taken)
+
+	current, err := ioutil.ReadFile(taken)
+	if err == nil && bytes.Equal(current, content) {
+		return true, nil
+	}
+
+	// The lock belongs to another process, unless yet another process acquired the lock since it was renamed
+	if err := os.Link(taken, path); err != nil && !os.IsExist(err) {
+		return false, fmt.Errorf("error restoring lock %s: %s", path, err.Error())
+	}
+	return false, nil
+}
+
+// stale returns true if the process that holds the lock doesn't hold it anymore.
+func (i Info) stale(host string) bool {
+	if i.Host == host && i.PID > 0 {
+		return !processRunning(i.PID)
+	}
+	return time.Since(i.Started) > StaleAfter
+}
+
+// Release removes the lock file. A lock that another process took over (like with --force) is left in place and a
+// *LockedError is returned.
+func (l *Lock) Release() error {
+	content, err := json.Marshal(l.Info)
+	if err != nil {
+		return fmt.Errorf("error removing lock %s: %s", l.Path, err.Error())
+	}
+
+	removed, err := remove(l.Path, content)
+	if err != nil || removed {
+		return err
+	}
+	if holder, err := Read(l.Path); err == nil {
+		return &LockedError{Path: l.Path, Holder: holder}
+	}
+	return nil
+}
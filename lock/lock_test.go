@@ -0,0 +1,163 @@
+package lock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LockTestSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func (suite *LockTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "fdio")
+	suite.Require().NoError(err)
+	suite.dir = dir
+	suite.path = filepath.Join(dir, "fdio.db.lock")
+}
+
+func (suite *LockTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *LockTestSuite) writeLock(info Info) {
+	content, _ := json.Marshal(info)
+	suite.Require().NoError(ioutil.WriteFile(suite.path, content, 0600))
+}
+
+func (suite *LockTestSuite) TestAcquireAndRelease() {
+	l, err := Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), os.Getpid(), l.Info.PID)
+
+	info, err := Read(suite.path)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), l.Info.PID, info.PID)
+	assert.Equal(suite.T(), l.Info.Host, info.Host)
+
+	_, err = Acquire(suite.path, false)
+	assert.Error(suite.T(), err)
+	assert.IsType(suite.T(), &LockedError{}, err)
+
+	assert.NoError(suite.T(), l.Release())
+	_, err = os.Stat(suite.path)
+	assert.True(suite.T(), os.IsNotExist(err))
+
+	l, err = Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), l.Release())
+}
+
+func (suite *LockTestSuite) TestForce() {
+	l, err := Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+
+	l2, err := Acquire(suite.path, true)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), l2.Info.Started.After(l.Info.Started) || l2.Info.Started.Equal(l.Info.Started))
+	assert.NoError(suite.T(), l2.Release())
+}
+
+func (suite *LockTestSuite) TestStaleLocks() {
+	host, _ := os.Hostname()
+
+	// A process that no longer runs on this host
+	suite.writeLock(Info{PID: 1 << 22, Host: host, Started: time.Now()})
+	l, err := Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), l.Release())
+
+	// A recent lock on another host
+	started := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	suite.writeLock(Info{PID: 1, Host: "another-host", Started: started})
+	_, err = Acquire(suite.path, false)
+	assert.EqualError(suite.T(), err, suite.path+" is locked by process 1 on another-host since "+started.Format(time.RFC3339))
+
+	// An old lock on another host
+	suite.writeLock(Info{PID: 1, Host: "another-host", Started: time.Now().Add(-2 * StaleAfter)})
+	l, err = Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), l.Release())
+}
+
+// acquireConcurrently tries to acquire the lock from many goroutines at the same time and returns the locks that
+// were acquired.
+func (suite *LockTestSuite) acquireConcurrently() []*Lock {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var locks []*Lock
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l, err := Acquire(suite.path, false); err == nil {
+				mu.Lock()
+				locks = append(locks, l)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return locks
+}
+
+func (suite *LockTestSuite) TestConcurrentAcquire() {
+	// The lock file is complete as soon as it exists, so only one of the processes gets the lock
+	locks := suite.acquireConcurrently()
+	assert.Len(suite.T(), locks, 1)
+
+	// Only the lock file is left behind
+	files, err := ioutil.ReadDir(suite.dir)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), files, 1)
+	assert.NoError(suite.T(), locks[0].Release())
+}
+
+func (suite *LockTestSuite) TestConcurrentTakeover() {
+	host, _ := os.Hostname()
+
+	// All processes find the same stale lock, but only one of them takes it over
+	for i := 0; i < 20; i++ {
+		suite.writeLock(Info{PID: 1 << 22, Host: host, Started: time.Now()})
+		locks := suite.acquireConcurrently()
+		suite.Require().Len(locks, 1)
+
+		files, err := ioutil.ReadDir(suite.dir)
+		assert.NoError(suite.T(), err)
+		assert.Len(suite.T(), files, 1)
+		assert.NoError(suite.T(), locks[0].Release())
+	}
+}
+
+func (suite *LockTestSuite) TestReleaseAfterTakeover() {
+	l, err := Acquire(suite.path, false)
+	assert.NoError(suite.T(), err)
+
+	l2, err := Acquire(suite.path, true)
+	assert.NoError(suite.T(), err)
+
+	// The process that lost the lock doesn't remove the lock of the process that took it over
+	err = l.Release()
+	assert.IsType(suite.T(), &LockedError{}, err)
+	info, err := Read(suite.path)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), l2.Info.Started, info.Started)
+
+	assert.NoError(suite.T(), l2.Release())
+	_, err = os.Stat(suite.path)
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+func TestLockTestSuite(t *testing.T) {
+	suite.Run(t, new(LockTestSuite))
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import "syscall"
+
+// processRunning returns true if a process with the pid is running on this host.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
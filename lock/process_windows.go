@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package lock
+
+import "os"
+
+// processRunning returns true if a process with the pid is running on this host. On Windows finding a process fails
+// when it doesn't exist.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}